@@ -0,0 +1,354 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcfg"
+	"github.com/gogf/gf/v2/os/gcmd"
+	"github.com/gogf/gf/v2/os/gfile"
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+const (
+	defaultPattern = "auth"
+
+	errorArgRequired     = "argument is required"
+	errorMemoryStore     = "cache mode keeps tokens in the memory of the server, only redis and file modes can be managed"
+	errorFileModeOnline  = "servers of file mode only read the file at start and overwrite it on every change, stop them and pass --offline"
+	errorInvalidData     = "data should be a json object"
+	errorInitGToken      = "init GToken failed, see the log above"
+	errorConfigAdapter   = "config adapter is not a file adapter"
+	errorSessionNotFound = "session not found"
+)
+
+// output is where commands write their results, replaced in tests
+var output io.Writer = os.Stdout
+
+// configArguments are accepted by every command, and select the config read like gtoken.NewFromConfig
+var configArguments = []gcmd.Argument{
+	{Name: "config", Short: "c", Brief: "config file, e.g. config/config.yaml, default is the one found by GoFrame"},
+	{Name: "pattern", Short: "p", Brief: "config section of GToken", Default: defaultPattern},
+}
+
+// writeArguments are accepted by commands changing the store
+var writeArguments = append([]gcmd.Argument{
+	{Name: "offline", Brief: "confirm no server uses the file of file mode, which is required to change it", Orphan: true},
+}, configArguments...)
+
+var (
+	Main = gcmd.Command{
+		Name:  "gtoken",
+		Usage: "gtoken COMMAND [ARGUMENT] [OPTION]",
+		Brief: "inspect tokens, and manage sessions in the store configured for gtoken.NewFromConfig",
+		Description: "Store commands work on redis mode, and on file mode while no server is running, since servers only read " +
+			"the file at start and overwrite it on every change. issue, revoke and import need --offline to confirm it in file mode. " +
+			"Cache mode keeps tokens in the memory of the server and cannot be managed. " +
+			"Tenants are configured in code, so only sessions without tenant are managed.",
+	}
+
+	Decode = gcmd.Command{
+		Name:      "decode",
+		Usage:     "gtoken decode TOKEN",
+		Brief:     "print the header and claims of a token without verifying it",
+		Arguments: []gcmd.Argument{{Name: "token", IsArg: true, Brief: "jwt"}},
+		Func: func(ctx context.Context, parser *gcmd.Parser) (err error) {
+			token, err := requireArg(parser, 2, "token")
+			if err != nil {
+				return err
+			}
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+			if err != nil {
+				return err
+			}
+			return printJson(g.Map{"header": parsed.Header, "claims": parsed.Claims})
+		},
+	}
+
+	Verify = gcmd.Command{
+		Name:  "verify",
+		Usage: "gtoken verify TOKEN [-k KEY] [-a ALGORITHM]",
+		Brief: "verify the signature of a token by the secret key and algorithm of the config, or the given ones",
+		Arguments: append([]gcmd.Argument{
+			{Name: "token", IsArg: true, Brief: "jwt"},
+			{Name: "key", Short: "k", Brief: "secret key, e.g. of a tenant, instead of secretKey of the config"},
+			{Name: "algorithm", Short: "a", Brief: "HS256, HS384 or HS512, instead of algorithm of the config"},
+		}, configArguments...),
+		Func: func(ctx context.Context, parser *gcmd.Parser) (err error) {
+			token, err := requireArg(parser, 2, "token")
+			if err != nil {
+				return err
+			}
+			cfg, err := loadConfig(ctx, parser)
+			if err != nil {
+				return err
+			}
+			secretKey, algorithm := cfg.SecretKey, cfg.Algorithm
+			if key := parser.GetOpt("key").String(); key != "" {
+				secretKey = []byte(key)
+			}
+			if alg := parser.GetOpt("algorithm").String(); alg != "" {
+				algorithm = alg
+			}
+			parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+				return secretKey, nil
+			}, jwt.WithValidMethods([]string{algorithm}))
+			if err != nil {
+				return printJson(g.Map{"valid": false, "error": err.Error()})
+			}
+			return printJson(g.Map{"valid": true, "header": parsed.Header, "claims": parsed.Claims})
+		},
+	}
+
+	Issue = gcmd.Command{
+		Name:  "issue",
+		Usage: "gtoken issue USER_ID [-r ROLES] [-s SCOPES] [-d DATA] [--offline]",
+		Brief: "issue a token for a user, e.g. to reproduce an issue of the user",
+		Arguments: append([]gcmd.Argument{
+			{Name: "userID", IsArg: true, Brief: "user id"},
+			{Name: "roles", Short: "r", Brief: "comma separated roles"},
+			{Name: "scopes", Short: "s", Brief: "comma separated scopes"},
+			{Name: "data", Short: "d", Brief: `extra data as a json object, e.g. {"name":"support"}`},
+		}, writeArguments...),
+		Func: func(ctx context.Context, parser *gcmd.Parser) (err error) {
+			userID, err := requireArg(parser, 2, "userID")
+			if err != nil {
+				return err
+			}
+			var extraData g.Map
+			if data := parser.GetOpt("data").String(); data != "" {
+				if err = gjson.DecodeTo(data, &extraData); err != nil {
+					return errors.New(errorInvalidData)
+				}
+			}
+			gt, err := newStoreGToken(ctx, parser, true)
+			if err != nil {
+				return err
+			}
+			var opts []gtoken.TokenOption
+			if roles := parser.GetOpt("roles").String(); roles != "" {
+				opts = append(opts, gtoken.WithRoles(gstr.SplitAndTrim(roles, ",")...))
+			}
+			if scopes := parser.GetOpt("scopes").String(); scopes != "" {
+				opts = append(opts, gtoken.WithScopes(gstr.SplitAndTrim(scopes, ",")...))
+			}
+			token, tokenInfo, err := gt.NewToken(ctx, userID, extraData, opts...)
+			if err != nil {
+				return err
+			}
+			return printJson(g.Map{"token": token, "session": tokenInfo})
+		},
+	}
+
+	List = gcmd.Command{
+		Name:  "list",
+		Usage: "gtoken list [USER_ID]",
+		Brief: "list live sessions of a user, or all of them",
+		Arguments: append([]gcmd.Argument{
+			{Name: "userID", IsArg: true, Brief: "user id, empty for all users"},
+		}, configArguments...),
+		Func: func(ctx context.Context, parser *gcmd.Parser) (err error) {
+			gt, err := newStoreGToken(ctx, parser, false)
+			if err != nil {
+				return err
+			}
+			var tokenInfos []*gtoken.TokenInfo
+			if userID := parser.GetArg(2).String(); userID != "" {
+				tokenInfos, err = gt.UserSessions(ctx, userID)
+			} else {
+				tokenInfos, err = gt.ExportSessions(ctx)
+			}
+			if err != nil {
+				return err
+			}
+			return printJson(tokenInfos)
+		},
+	}
+
+	Revoke = gcmd.Command{
+		Name:  "revoke",
+		Usage: "gtoken revoke TOKEN_ID | gtoken revoke -i TOKEN_ID | gtoken revoke -u USER_ID [--offline]",
+		Brief: "revoke a session by its token id, or all sessions of a user",
+		Arguments: append([]gcmd.Argument{
+			{Name: "id", IsArg: true, Brief: "token id, or user id with -u"},
+			{Name: "tokenID", Short: "i", Brief: `token id, for ids starting with "-", which are read as options instead of the argument`},
+			{Name: "user", Short: "u", Brief: "revoke all sessions of the user", Orphan: true},
+		}, writeArguments...),
+		Func: func(ctx context.Context, parser *gcmd.Parser) (err error) {
+			id := parser.GetOpt("tokenID").String()
+			if id == "" {
+				if id, err = requireArg(parser, 2, "id"); err != nil {
+					return err
+				}
+			}
+			gt, err := newStoreGToken(ctx, parser, true)
+			if err != nil {
+				return err
+			}
+			if parser.GetOpt("user") != nil {
+				revoked, err1 := gt.RevokeUserSessions(ctx, id)
+				if err1 != nil {
+					return err1
+				}
+				return printJson(g.Map{"revoked": revoked})
+			}
+			if err = gt.RevokeSession(ctx, id); err != nil {
+				// gcmd prints the usage for errors of gcode.CodeNotFound, as if the command was not found
+				if gerror.Code(err) == gcode.CodeNotFound {
+					return fmt.Errorf("%s: %q", errorSessionNotFound, id)
+				}
+				return err
+			}
+			return printJson(g.Map{"revoked": 1})
+		},
+	}
+
+	Export = gcmd.Command{
+		Name:  "export",
+		Usage: "gtoken export [FILE]",
+		Brief: "export live sessions as json, e.g. to move them to another store by import",
+		Arguments: append([]gcmd.Argument{
+			{Name: "file", IsArg: true, Brief: "output file, empty for stdout"},
+		}, configArguments...),
+		Func: func(ctx context.Context, parser *gcmd.Parser) (err error) {
+			gt, err := newStoreGToken(ctx, parser, false)
+			if err != nil {
+				return err
+			}
+			tokenInfos, err := gt.ExportSessions(ctx)
+			if err != nil {
+				return err
+			}
+			file := parser.GetArg(2).String()
+			if file == "" {
+				return printJson(tokenInfos)
+			}
+			content, err := gjson.MarshalIndent(tokenInfos, "", "  ")
+			if err != nil {
+				return err
+			}
+			if err = gfile.PutBytes(file, content); err != nil {
+				return err
+			}
+			return printJson(g.Map{"exported": len(tokenInfos)})
+		},
+	}
+
+	Import = gcmd.Command{
+		Name:  "import",
+		Usage: "gtoken import [FILE] [--offline]",
+		Brief: "import sessions exported by export until their original expiry, expired ones are skipped",
+		Arguments: append([]gcmd.Argument{
+			{Name: "file", IsArg: true, Brief: "input file, empty for stdin"},
+		}, writeArguments...),
+		Func: func(ctx context.Context, parser *gcmd.Parser) (err error) {
+			var content []byte
+			if file := parser.GetArg(2).String(); file != "" {
+				if content, err = os.ReadFile(file); err != nil {
+					return err
+				}
+			} else if content, err = io.ReadAll(os.Stdin); err != nil {
+				return err
+			}
+			var tokenInfos []*gtoken.TokenInfo
+			if err = gjson.DecodeTo(content, &tokenInfos); err != nil {
+				return err
+			}
+			gt, err := newStoreGToken(ctx, parser, true)
+			if err != nil {
+				return err
+			}
+			imported, err := gt.ImportSessions(ctx, tokenInfos)
+			if err != nil {
+				return err
+			}
+			return printJson(g.Map{"imported": imported, "skipped": len(tokenInfos) - imported})
+		},
+	}
+)
+
+func init() {
+	if err := Main.AddCommand(&Decode, &Verify, &Issue, &List, &Revoke, &Export, &Import); err != nil {
+		panic(err)
+	}
+}
+
+// loadConfig reads the config of GToken from the file and section of the options
+func loadConfig(ctx context.Context, parser *gcmd.Parser) (*gtoken.Config, error) {
+	if err := useConfigFile(parser); err != nil {
+		return nil, err
+	}
+	cfg, err := gtoken.LoadConfig(ctx, parser.GetOpt("pattern", defaultPattern).String())
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.SecretKey) == 0 {
+		cfg.SecretKey = []byte(gtoken.DefaultSecretKey)
+	}
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = gtoken.DefaultAlgorithm
+	}
+	return cfg, nil
+}
+
+// useConfigFile makes GoFrame read the config file of the option, instead of searching config.yaml
+func useConfigFile(parser *gcmd.Parser) error {
+	file := parser.GetOpt("config").String()
+	if file == "" {
+		return nil
+	}
+	fileConfig, ok := g.Cfg().GetAdapter().(*gcfg.AdapterFile)
+	if !ok {
+		return errors.New(errorConfigAdapter)
+	}
+	fileConfig.SetFileName(file)
+	return nil
+}
+
+// newStoreGToken returns a GToken built like gtoken.NewFromConfig, whose store is shared with the servers.
+// A command changing the store in file mode needs --offline, or its change is lost when a server saves the file.
+func newStoreGToken(ctx context.Context, parser *gcmd.Parser, write bool) (*gtoken.GToken, error) {
+	if err := useConfigFile(parser); err != nil {
+		return nil, err
+	}
+	gt, err := gtoken.NewFromConfig(ctx, parser.GetOpt("pattern", defaultPattern).String())
+	if err != nil {
+		return nil, err
+	}
+	if gt.CacheMode == gtoken.CacheModeCache {
+		return nil, errors.New(errorMemoryStore)
+	}
+	if write && gt.CacheMode == gtoken.CacheModeFile && parser.GetOpt("offline") == nil {
+		return nil, errors.New(errorFileModeOnline)
+	}
+	if !gt.Init(ctx) {
+		return nil, errors.New(errorInitGToken)
+	}
+	return gt, nil
+}
+
+func requireArg(parser *gcmd.Parser, index int, name string) (string, error) {
+	value := parser.GetArg(index).String()
+	if value == "" {
+		return "", fmt.Errorf("%s: %s", errorArgRequired, name)
+	}
+	return value, nil
+}
+
+func printJson(value any) error {
+	content, err := gjson.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(output, string(content))
+	return err
+}
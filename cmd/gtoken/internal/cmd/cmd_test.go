@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/os/gfile"
+)
+
+func TestCommands(t *testing.T) {
+	t.Log("test: gtoken commands")
+	ctx := context.Background()
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	err := gfile.PutContents(configFile, `
+auth:
+  secretKey: "cli-secret"
+  expireIn: 1h
+  store:
+    mode: file
+memory:
+  store:
+    mode: cache
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffer := &bytes.Buffer{}
+	output = buffer
+	// run runs a command with the config file, and returns its json output
+	run := func(args ...string) (*gjson.Json, error) {
+		buffer.Reset()
+		args = append(append([]string{"gtoken"}, args...), "-c", configFile)
+		_, err1 := Main.RunWithSpecificArgs(ctx, args)
+		return gjson.New(buffer.String()), err1
+	}
+
+	t.Log("1. a token is issued, decoded and verified")
+	res, err := run("issue", "cli-user", "-r", "support", "-d", `{"name":"Support"}`, "--offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, tokenID := res.Get("token").String(), res.Get("session.tokenID").String()
+	if token == "" || res.Get("session.roles.0").String() != "support" || res.Get("session.extraData.name").String() != "Support" {
+		t.Fatal("error: issued session is not correct:", res.String())
+	}
+	defer func() {
+		_, _ = run("revoke", "-u", "cli-user", "--offline")
+	}()
+	if res, err = run("decode", token); err != nil || res.Get("claims.jti").String() != tokenID {
+		t.Error("error: decoded claims are not correct:", res.String(), err)
+	}
+	if res, err = run("verify", token); err != nil || !res.Get("valid").Bool() {
+		t.Error("error: token should be valid:", res.String(), err)
+	}
+	if res, err = run("verify", token, "-k", "another-secret"); err != nil || res.Get("valid").Bool() {
+		t.Error("error: token should be invalid by another key:", res.String(), err)
+	}
+	if _, err = run("issue", "cli-user", "-d", "[1]", "--offline"); err == nil {
+		t.Error("error: data which is not a json object should be rejected")
+	}
+
+	t.Log("2. sessions are listed, exported, revoked and imported")
+	// token ids may start with "-", which gcmd reads as an option, so they are passed by -i
+	if res, err = run("list", "cli-user"); err != nil || len(res.Array()) != 1 || res.Get("0.tokenID").String() != tokenID {
+		t.Error("error: sessions are not correct:", res.String(), err)
+	}
+	exportFile := filepath.Join(dir, "sessions.json")
+	if res, err = run("export", exportFile); err != nil || res.Get("exported").Int() < 1 {
+		t.Fatal("error: export failed:", res.String(), err)
+	}
+	if res, err = run("revoke", "-i", tokenID, "--offline"); err != nil || res.Get("revoked").Int() != 1 {
+		t.Error("error: revoke failed:", res.String(), err)
+	}
+	if _, err = run("revoke", "-i", tokenID, "--offline"); err == nil {
+		t.Error("error: revoked session should not be found")
+	}
+	if _, err = run("revoke", "-i", "-dashed-id", "--offline"); err == nil || !strings.HasPrefix(err.Error(), errorSessionNotFound) {
+		t.Error("error: token id starting with \"-\" should be read by -i, but:", err)
+	}
+	if res, err = run("import", exportFile, "--offline"); err != nil || res.Get("imported").Int() < 1 {
+		t.Error("error: import failed:", res.String(), err)
+	}
+	if res, err = run("revoke", "-u", "cli-user", "--offline"); err != nil || res.Get("revoked").Int() != 1 {
+		t.Error("error: imported session should be revoked by user:", res.String(), err)
+	}
+
+	t.Log("3. the file of file mode is only changed offline, since servers overwrite it")
+	for _, args := range [][]string{{"issue", "cli-user"}, {"revoke", "-i", tokenID}, {"import", exportFile}} {
+		if _, err = run(args...); err == nil || err.Error() != errorFileModeOnline {
+			t.Error("error: change without --offline should be rejected:", args, err)
+		}
+	}
+
+	t.Log("4. the memory of cache mode cannot be managed")
+	if _, err = run("list", "-p", "memory"); err == nil || err.Error() != errorMemoryStore {
+		t.Error("error: cache mode should be rejected, but:", err)
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	_ "github.com/gogf/gf/contrib/nosql/redis/v2"
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/mayugene/gtoken/cmd/gtoken/internal/cmd"
+)
+
+func main() {
+	cmd.Main.Run(gctx.GetInitCtx())
+}
@@ -3,9 +3,7 @@ package cmd
 import (
 	"context"
 	"net/http"
-	"strings"
 	"sync"
-	"time"
 
 	"github.com/gogf/gf/v2/errors/gcode"
 	"github.com/gogf/gf/v2/errors/gerror"
@@ -23,20 +21,10 @@ var (
 
 func UseGToken() *gtoken.GToken {
 	once.Do(func() {
-		ctx := context.TODO()
-		// err can be omitted while getting configs
-		cacheMode, _ := g.Cfg().Get(ctx, "auth.cacheMode")
-		singleSession, _ := g.Cfg().Get(ctx, "auth.singleSession")
-		publicPaths, _ := g.Cfg().Get(ctx, "auth.publicPaths")
-		autoRefreshToken, _ := g.Cfg().Get(ctx, "auth.autoRefreshToken")
-		expireIn, _ := g.Cfg().Get(ctx, "auth.expireIn")
-
-		gtokenInstance = &gtoken.GToken{
-			CacheMode:        cacheMode.Uint8(),
-			PublicPaths:      strings.Split(publicPaths.String(), ","),
-			SingleSession:    singleSession.Bool(),
-			AutoRefreshToken: autoRefreshToken.Bool(),
-			ExpireIn:         time.Duration(expireIn.Int64()) * time.Second,
+		var err error
+		gtokenInstance, err = gtoken.NewFromConfig(context.TODO(), "auth")
+		if err != nil {
+			panic(err)
 		}
 	})
 	return gtokenInstance
@@ -76,6 +64,12 @@ func SystemInit(s *ghttp.Server, ctx context.Context) {
 		})
 	})
 
+	// logout of any client by RFC 7009, which writes its own response
+	s.Group("/oauth", func(group *ghttp.RouterGroup) {
+		group.Middleware(MiddlewareCORS)
+		group.POST("/revoke", ghttp.WrapH(gtokenInstance.RevocationHandler(nil)))
+	})
+
 	s.Group("/", func(group *ghttp.RouterGroup) {
 		group.Middleware(MiddlewareCORS)
 		group.Middleware(MiddlewareHandlerResponse)
@@ -86,7 +80,7 @@ func SystemInit(s *ghttp.Server, ctx context.Context) {
 		}
 
 		group.GET("/user", func(r *ghttp.Request) {
-			r.Response.WriteJson(gtoken.DefaultResponse{Msg: "get user success"})
+			r.Response.WriteJson(gtoken.DefaultResponse{Msg: "get user success", Data: g.Map{"userId": gtoken.UserIDFromContext(r.Context())}})
 		})
 		group.POST("/user/data", func(r *ghttp.Request) {
 			r.Response.WriteJson(gtoken.DefaultResponse{Data: g.Map{"id": 33, "name": "abc"}})
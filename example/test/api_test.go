@@ -9,6 +9,7 @@ import (
 	"github.com/mayugene/gtoken/example/internal/cmd"
 	_ "github.com/mayugene/gtoken/example/internal/logic"
 	"github.com/mayugene/gtoken/gtoken"
+	"net/http"
 	"os"
 	"testing"
 	"time"
@@ -109,6 +110,29 @@ func TestLogout(t *testing.T) {
 	}
 }
 
+func TestRevoke(t *testing.T) {
+	t.Log("test: revoke a token by RFC 7009")
+	testToken, err := GetToken(t)
+	if err != nil || testToken == "" {
+		t.Error("get token error:", err)
+	}
+	res, err := g.Client().Post(context.TODO(), ApiRevoke, "token="+testToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Error("error: code should be 200, but:", res.StatusCode)
+	}
+	userRes, err := Get(t, testToken, ApiUser)
+	if err != nil {
+		t.Error("error:", err)
+	}
+	if userRes.Code != gtoken.DefaultCodeUnauthorized {
+		t.Errorf("code should be %d, but: %v", gtoken.DefaultCodeUnauthorized, userRes)
+	}
+}
+
 func TestUser(t *testing.T) {
 	t.Log("1. not login and get user")
 	res, err := Get(t, "", ApiUser)
@@ -23,6 +23,7 @@ const (
 	ApiHello      = baseURL + "/hello"
 	ApiLogin      = baseURL + "/login"
 	ApiLogout     = baseURL + "/logout"
+	ApiRevoke     = baseURL + "/oauth/revoke"
 	ApiUser       = baseURL + "/user"
 	ApiUserData   = baseURL + "/user/data"
 	ApiUserPublic = baseURL + "/user/public"
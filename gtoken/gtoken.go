@@ -3,14 +3,21 @@ package gtoken
 import (
 	"context"
 	"errors"
-	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/gogf/gf/v2/database/gredis"
 	"github.com/gogf/gf/v2/errors/gcode"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/net/ghttp"
 	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type GToken struct {
@@ -19,16 +26,58 @@ type GToken struct {
 	SingleSession    bool                                        // if true, only one token can be kept, so the old one will be deleted
 	AutoRefreshToken bool                                        // whether refresh a token automatically. It is a big risk to use "true" in production
 	SecretKey        []byte                                      // jwt secret key, why use []byte: https://golang-jwt.github.io/jwt/usage/signing_methods/#frequently-asked-questions
+	Algorithm        string                                      // jwt signing algorithm, HS256 (default), HS384 or HS512
+	RedisGroup       string                                      // redis group in config used by CacheModeRedis, default "default"
+	Redis            *gredis.Redis                               // redis client used by CacheModeRedis instead of RedisGroup, e.g. a dedicated instance for sessions
 	TokenIDLength    uint8                                       // length of NanoID, default 12
-	PublicPaths      []string                                    // non-auth paths. Support restful formats like "POST:/login"
+	PublicPaths      []string                                    // non-auth paths. Support restful formats like "POST:/login", "GET,HEAD:/docs/*", "/user/{id}" and "~regex"
+	PublicMethods    []string                                    // non-auth gRPC methods like "/pkg.Service/Method" or "/pkg.Service/*". Same formats as PublicPaths
+	OptionalPaths    []string                                    // paths where a token is validated if present, or the request goes on anonymously. Same formats as PublicPaths
+	RejectInvalid    bool                                        // if true, an invalid or expired token on optional paths is rejected instead of being treated as anonymous
 	DoBeforeAuth     func(r *ghttp.Request) (ok bool)            // generally, we omit the file requests in this func
 	DoAfterAuth      func(r *ghttp.Request, ok bool, data g.Map) // generally, we add info into context in this func
+	DoForbidden      func(r *ghttp.Request, data g.Map)          // called when an authenticated session is not allowed, e.g. lacking permissions
+	RBAC             *RBAC                                       // role -> permission registry used by Require and HasPermission
+	ScopeRules       map[string][]string                         // path rule -> required scopes, e.g. "POST:/orders/*": {"orders:write"}. Same formats as PublicPaths. Tokens without scopes are not restricted
+	ScopeClaim       bool                                        // if true, scopes are also embedded in the jwt "scope" claim
+	Policy           Policy                                      // attribute-based decisions evaluated after scopes, e.g. a RulePolicy
+	Tenants          map[string]*Tenant                          // tenant id -> settings. Tokens of a tenant never validate under another one
+	TenantResolver   TenantResolver                              // resolves the tenant of a request, e.g. TenantFromHeader("X-Tenant-ID"). Required if Tenants is set
+	OnIssue          []TokenListener                             // called after NewToken creates a token
+	OnValidate       []TokenListener                             // called after ValidateToken succeeds, e.g. to update last-seen timestamps
+	OnRefresh        []TokenListener                             // called after AutoRefreshToken refreshes a token
+	OnRevoke         []TokenListener                             // called after a token is removed, including evictions by SingleSession
+	AsyncEvents      bool                                        // if true, listeners are called by a background queue instead of blocking the caller
+	EventQueueSize   int                                         // size of the async queue, default 1024. Events are dropped when it is full
+	AuditWriter      io.Writer                                   // if set, security audit events are written as json lines, e.g. NewAuditFile("/var/log/gtoken/audit.log")
+	AuditRedactKeys  []string                                    // param keys redacted in audit events and auth failure logs, default DefaultAuditRedactKeys
+	Logger           Logger                                      // receives logs of gtoken, e.g. NewSlogLogger(slog.Default()) or NopLogger. Default g.Log()
+	MeterProvider    metric.MeterProvider                        // provider of otel metrics of token operations. Default the global one of otel
+	SessionsGauge    bool                                        // if true, the gtoken.sessions.active gauge counts tokens at every collection, which scans the whole store
+	TracerProvider   trace.TracerProvider                        // provider of otel spans of auth and store calls. Default the global one of otel
+
+	current    *atomic.Pointer[settings] // snapshot of reloadable settings, built in Init and swapped by Reload
+	scopeRules []scopeRule               // compiled ScopeRules, built in Init
+
+	tenantViews map[string]*GToken // tenant id -> view of GToken, built in Init
+	tenantID    string             // tenant of a view, "" if it is not a view
+	keyPrefix   string             // "tenant:{tenantID}:" of a view, prefixed to cache keys
+
+	webSockets *webSocketRegistry // WebSocket connections by token, built in Init
+	events     *eventQueue        // async queue of listeners, built in Init if AsyncEvents is true
+	audits     *auditLog          // writer of audit events, built in Init if AuditWriter is set
+	metrics    *tokenMetrics      // otel instruments, built in Init
 }
 
 type TokenInfo struct {
 	UserID    string      `json:"userID"`
 	TokenID   string      `json:"tokenID"`
+	TenantID  string      `json:"tenantID,omitempty"`
 	ExtraData g.Map       `json:"extraData"`
+	Roles     []string    `json:"roles,omitempty"`
+	Scopes    []string    `json:"scopes,omitempty"`
+	ClientID  string      `json:"clientID,omitempty"`
+	IssuedAt  *gtime.Time `json:"issuedAt,omitempty"`
 	ExpireAt  *gtime.Time `json:"ExpireAt"`
 	RefreshAt *gtime.Time `json:"RefreshAt"`
 }
@@ -39,73 +88,133 @@ type DefaultResponse struct {
 	Data interface{} `json:"data"`
 }
 
+// TokenOption sets optional fields of TokenInfo in NewToken
+type TokenOption func(tokenInfo *TokenInfo)
+
+// WithRoles sets the roles of a new token, which are checked by GToken.RBAC
+func WithRoles(roles ...string) TokenOption {
+	return func(tokenInfo *TokenInfo) {
+		tokenInfo.Roles = roles
+	}
+}
+
+// WithClientID sets the client a new token is issued to, e.g. the OAuth client id returned by token introspection
+func WithClientID(clientID string) TokenOption {
+	return func(tokenInfo *TokenInfo) {
+		tokenInfo.ClientID = clientID
+	}
+}
+
 // NewToken returns a new token
-func (m *GToken) NewToken(ctx context.Context, userID string, extraData g.Map) (token string, tokenInfo *TokenInfo, err error) {
+func (m *GToken) NewToken(ctx context.Context, userID string, extraData g.Map, opts ...TokenOption) (token string, tokenInfo *TokenInfo, err error) {
+	ctx, span := m.startSpan(ctx, SpanNewToken)
+	defer func() {
+		endTokenSpan(span, tokenInfo, err)
+	}()
 	// if SingleSession is false, a user can create tokens without limitation.
 	// else, only one token could be kept. (The new token will replace the old one)
 	if userID == "" {
 		return "", nil, errors.New("a valid userId is required")
 	}
 
-	if m.SingleSession {
+	s := m.settings()
+	if s.config.SingleSession {
 		// delete the old one
-		ok, err1 := m.removeUserCache(ctx, userID)
+		tokenInfos, ok, err1 := m.removeUserCache(ctx, userID)
 		if err1 != nil {
 			return "", nil, err1
 		}
 		if !ok {
 			return "", nil, errors.New(gcode.CodeInternalError.Message())
 		}
+		m.revoked(ctx, ReasonSessionReplaced, tokenInfos...)
 	}
 
-	newToken, newTokenID, err := m.encrypt()
-	if err != nil {
-		return "", nil, err
-	}
 	tokenInfo = &TokenInfo{
 		UserID:    userID,
-		TokenID:   newTokenID,
+		TenantID:  m.tenantID,
 		ExtraData: extraData,
-		ExpireAt:  gtime.Now().Add(m.ExpireIn),
-		RefreshAt: gtime.Now().Add(m.ExpireIn / 2),
+		IssuedAt:  gtime.Now(),
+		ExpireAt:  gtime.Now().Add(s.config.ExpireIn),
+		RefreshAt: gtime.Now().Add(s.config.ExpireIn / 2),
+	}
+	for _, opt := range opts {
+		opt(tokenInfo)
 	}
+	newToken, newTokenID, err := m.encrypt(s.config.TokenIDLength, tokenInfo.Scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	tokenInfo.TokenID = newTokenID
 
-	ok, err := m.setTokenCache(ctx, newToken, tokenInfo)
+	ok, err := m.setTokenCache(ctx, newToken, tokenInfo, s.config.ExpireIn)
 	if !ok {
 		return "", nil, err
 	}
+	m.recordIssued(ctx)
+	m.emit(ctx, m.OnIssue, TokenEvent{Type: EventIssue, TokenInfo: tokenInfo})
 	return newToken, tokenInfo, nil
 }
 
 // ValidateToken returns token info. If AutoRefreshToken is true, refresh token ttl.
 func (m *GToken) ValidateToken(ctx context.Context, token string) (*TokenInfo, error) {
+	ctx, span := m.startSpan(ctx, SpanValidateToken)
+	tokenInfo, err := m.validateToken(ctx, token, true)
+	m.recordValidation(ctx, err)
+	endTokenSpan(span, tokenInfo, err)
+	return tokenInfo, err
+}
+
+// validateToken validates a token, and refreshes it by AutoRefreshToken if refresh is true
+func (m *GToken) validateToken(ctx context.Context, token string, refresh bool) (*TokenInfo, error) {
 	tokenInfo, err := m.getTokenCache(ctx, token)
 	if err != nil {
 		return nil, err
 	}
+	if err = m.checkTenant(tokenInfo); err != nil {
+		return nil, err
+	}
 
 	// handle auto refresh token
-	if m.AutoRefreshToken && gtime.Now().Sub(tokenInfo.RefreshAt) > 0 {
-		tokenInfo.ExpireAt = gtime.Now().Add(m.ExpireIn)
-		tokenInfo.RefreshAt = gtime.Now().Add(m.ExpireIn / 2)
-		if ok, err1 := m.refreshTokenCache(ctx, token, tokenInfo); ok {
-			return tokenInfo, nil
-		} else {
+	if s := m.settings(); refresh && s.config.AutoRefreshToken && gtime.Now().Sub(tokenInfo.RefreshAt) > 0 {
+		tokenInfo.ExpireAt = gtime.Now().Add(s.config.ExpireIn)
+		tokenInfo.RefreshAt = gtime.Now().Add(s.config.ExpireIn / 2)
+		if ok, err1 := m.refreshTokenCache(ctx, token, tokenInfo); !ok {
 			return nil, err1
 		}
+		m.recordRefresh(ctx)
+		m.emit(ctx, m.OnRefresh, TokenEvent{Type: EventRefresh, TokenInfo: tokenInfo})
 	}
 
+	m.emit(ctx, m.OnValidate, TokenEvent{Type: EventValidate, TokenInfo: tokenInfo})
 	return tokenInfo, nil
 }
 
-// RemoveToken deletes Token
+// RemoveToken deletes Token, fires OnRevoke and closes WebSocket connections bound to it
 func (m *GToken) RemoveToken(ctx context.Context, token string) (ok bool, err error) {
-	return m.removeTokenCache(ctx, token)
+	tokenInfo, err := m.getTokenCache(ctx, token)
+	if err != nil {
+		return false, err
+	}
+	ok, err = m.removeTokenCache(ctx, token)
+	if ok {
+		m.revoked(ctx, ReasonTokenRevoked, tokenInfo)
+	}
+	return ok, err
+}
+
+// RemoveUserTokens deletes all tokens of a user, fires OnRevoke and closes WebSocket connections bound to them
+func (m *GToken) RemoveUserTokens(ctx context.Context, userID string) (ok bool, err error) {
+	tokenInfos, ok, err := m.removeUserCache(ctx, userID)
+	if ok {
+		m.revoked(ctx, ReasonTokenRevoked, tokenInfos...)
+	}
+	return ok, err
 }
 
 func (m *GToken) Init(ctx context.Context) bool {
 	if m.CacheMode == CacheModeFile {
-		initCacheModeFile(ctx)
+		m.initCacheModeFile(ctx)
 	}
 	if m.ExpireIn == 0 {
 		m.ExpireIn = DefaultExpireIn
@@ -119,6 +228,26 @@ func (m *GToken) Init(ctx context.Context) bool {
 		m.TokenIDLength = DefaultTokenIDLength
 	}
 
+	if m.Algorithm == "" {
+		m.Algorithm = DefaultAlgorithm
+	}
+	if _, ok := jwt.GetSigningMethod(m.Algorithm).(*jwt.SigningMethodHMAC); !ok {
+		m.log(ctx, LogLevelError, errorInvalidAlgorithm, "algorithm", m.Algorithm)
+		return false
+	}
+
+	current, err := newSettings(m.fieldConfig())
+	if err != nil {
+		m.log(ctx, LogLevelError, err.Error())
+		return false
+	}
+	scopeRules, err := newScopeRules(m.ScopeRules)
+	if err != nil {
+		m.log(ctx, LogLevelError, err.Error())
+		return false
+	}
+	m.scopeRules = scopeRules
+
 	if m.DoBeforeAuth == nil {
 		m.DoBeforeAuth = func(r *ghttp.Request) bool {
 			return !r.IsFileRequest()
@@ -142,10 +271,7 @@ func (m *GToken) Init(ctx context.Context) bool {
 					return
 				}
 
-				g.Log().Debug(
-					r.Context(),
-					fmt.Sprintf("[AUTH_%s][url:%s][params:%s]", gtime.Now().String(), r.URL.Path, params),
-				)
+				m.log(r.Context(), LogLevelDebug, errorUnauthorized, "url", r.URL.Path, "params", m.redactParams(params))
 				r.Response.WriteJson(DefaultResponse{
 					Code: DefaultCodeUnauthorized,
 					Msg:  errorUnauthorized,
@@ -156,6 +282,50 @@ func (m *GToken) Init(ctx context.Context) bool {
 		}
 	}
 
+	if m.DoForbidden == nil {
+		m.DoForbidden = func(r *ghttp.Request, data g.Map) {
+			r.Response.WriteJson(DefaultResponse{
+				Code: DefaultCodeForbidden,
+				Msg:  errorForbidden,
+				Data: data,
+			})
+			r.ExitAll()
+		}
+	}
+
+	if m.webSockets == nil {
+		m.webSockets = newWebSocketRegistry()
+	}
+	if m.AuditRedactKeys == nil {
+		m.AuditRedactKeys = DefaultAuditRedactKeys
+	}
+	if m.AuditWriter != nil && m.audits == nil {
+		m.audits = newAuditLog(m.AuditWriter, m.AuditRedactKeys)
+	}
+	if m.metrics == nil {
+		if err = m.initMetrics(); err != nil {
+			m.log(ctx, LogLevelError, err.Error())
+			return false
+		}
+	}
+	if m.AsyncEvents && m.events == nil {
+		if m.EventQueueSize <= 0 {
+			m.EventQueueSize = DefaultEventQueueSize
+		}
+		m.events = newEventQueue(m.EventQueueSize, m.log)
+	}
+
+	// tenant views copy the settings above, so build them at last
+	if len(m.Tenants) > 0 && m.TenantResolver == nil {
+		m.log(ctx, LogLevelError, errorTenantResolverNotSet)
+		return false
+	}
+	if err = m.initTenants(); err != nil {
+		m.log(ctx, LogLevelError, err.Error())
+		return false
+	}
+	m.storeSettings(current)
+
 	return true
 }
 
@@ -169,14 +339,26 @@ func (m *GToken) UseMiddleware(ctx context.Context, group *ghttp.RouterGroup) er
 
 // authMiddleware should be used as a group middleware
 func (m *GToken) authMiddleware(r *ghttp.Request) {
-	// handle excluded paths
-	if !CheckAuthRequired(m.PublicPaths, r.URL.Path, r.Method) {
+	// the span only covers auth, so it is ended before the next handlers.
+	// span.End is idempotent, and the deferred one ends it if DoAfterAuth or DoForbidden exits the request.
+	ctx, span := m.startSpan(r.Context(), SpanAuthMiddleware, attribute.String(TraceAttrRoute, r.URL.Path))
+	defer span.End()
+
+	// read the settings once, so a Reload in the middle does not mix two configs
+	s := m.settings()
+
+	// handle excluded paths and route level declarations
+	mode := m.authMode(r, s)
+	span.SetAttributes(attribute.String(TraceAttrAuthMode, mode))
+	if mode == AuthModePublic {
+		endSpan(span, TraceOutcomePublic, nil)
 		r.Middleware.Next()
 		return
 	}
 
 	// handle user defined non-auth conditions
 	if !m.DoBeforeAuth(r) {
+		endSpan(span, TraceOutcomeSkipped, nil)
 		r.Middleware.Next()
 		return
 	}
@@ -184,22 +366,91 @@ func (m *GToken) authMiddleware(r *ghttp.Request) {
 	// perform auth
 	// first get token from request
 	token := ParseRequestToken(r)
+	// anonymous requests are allowed on optional paths
+	if token == "" && mode == AuthModeOptional {
+		endSpan(span, TraceOutcomeAnonymous, nil)
+		r.Middleware.Next()
+		return
+	}
+	// resolve the tenant, a request of an unknown tenant is unauthorized
+	gt, err := m.ForRequest(r.Request)
+	if err != nil {
+		m.auditValidationFailed(r.Request, err.Error())
+		endSpan(span, TraceOutcomeRejected, err)
+		m.DoAfterAuth(r, false, nil)
+		return
+	}
 	var ok bool
 	var extraData g.Map
 	if token != "" {
-		userToken, err := m.ValidateToken(r.Context(), token)
-		if err == nil {
+		userToken, err1 := gt.ValidateToken(ctx, token)
+		if err1 == nil {
+			// the default DoForbidden exits the request, so mark the span before checks, and the deferred End ends it
+			span.SetAttributes(attribute.String(TraceAttrOutcome, TraceOutcomeRejected))
+			if !gt.checkScopes(r, userToken) || !gt.checkPolicy(r, userToken) {
+				endSpan(span, TraceOutcomeRejected, errors.New(errorForbidden))
+				return
+			}
 			ok = true
 			extraData = userToken.ExtraData
+			// marked, so MountAdmin in a group of this middleware does not validate it again
+			r.SetCtx(context.WithValue(NewContext(r.Context(), userToken), authenticatedByCtxKey{}, m))
+		} else {
+			gt.auditValidationFailed(r.Request, err1.Error())
+			if mode == AuthModeOptional && !s.config.RejectInvalid {
+				endSpan(span, TraceOutcomeAnonymous, nil)
+				r.Middleware.Next()
+				return
+			}
+			err = err1
 		}
+	} else {
+		gt.auditValidationFailed(r.Request, errorTokenEmpty)
+		err = errors.New(errorTokenEmpty)
+	}
+	if ok {
+		endSpan(span, TraceOutcomeAuthorized, nil)
+	} else {
+		endSpan(span, TraceOutcomeRejected, err)
 	}
 	m.DoAfterAuth(r, ok, extraData)
 }
 
+// authMode returns how a request should be authenticated.
+// The auth tag in g.Meta of the matched handler takes priority over PublicPaths and OptionalPaths, e.g.
+//
+//	type HelloReq struct {
+//	    g.Meta `path:"/hello" method:"get" auth:"false"`
+//	}
+func (m *GToken) authMode(r *ghttp.Request, s *settings) string {
+	switch mode := strings.ToLower(r.GetServeHandler().GetMetaTag(MetaTagAuth)); mode {
+	case AuthModeRequired, AuthModePublic, AuthModeOptional:
+		return mode
+	case "":
+	default:
+		m.log(r.Context(), LogLevelWarning, errorInvalidMetaTag, "mode", mode, "path", r.URL.Path)
+	}
+	return s.pathMode(r.URL.Path, r.Method)
+}
+
+// pathMode returns how a request should be authenticated by PublicPaths and OptionalPaths only
+func (s *settings) pathMode(urlPath string, urlMethod string) string {
+	if s.publicMatcher.matches(urlPath, urlMethod) {
+		return AuthModePublic
+	}
+	if s.optionalMatcher.matches(urlPath, urlMethod) {
+		return AuthModeOptional
+	}
+	return AuthModeRequired
+}
+
 // encrypt return a valid token
-func (m *GToken) encrypt() (token string, id string, err error) {
-	id = getNanoID(m.TokenIDLength)
-	token, err = encryptJWT(m.SecretKey, id)
+func (m *GToken) encrypt(idLength uint8, scopes []string) (token string, id string, err error) {
+	id = getNanoID(idLength)
+	if !m.ScopeClaim {
+		scopes = nil
+	}
+	token, err = m.encryptJWT(id, scopes...)
 	if err != nil {
 		return "", "", errors.New(errorTokenEncrypt)
 	}
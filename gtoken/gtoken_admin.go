@@ -0,0 +1,272 @@
+package gtoken
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/os/gcache"
+)
+
+// AdminStatsReq counts active sessions of a tenant in the store
+type AdminStatsReq struct {
+	g.Meta `path:"/stats" method:"get" auth:"true" tags:"Session Admin" summary:"Count active sessions"`
+	Tenant string `json:"tenant" in:"query" dc:"tenant id, the tenant of the caller by default"`
+}
+
+type AdminStatsRes struct {
+	ActiveSessions int64  `json:"activeSessions" dc:"number of tokens of the tenant in the store"`
+	Backend        string `json:"backend" dc:"cache, redis or file"`
+}
+
+// AdminListUserSessionsReq lists live sessions of a user
+type AdminListUserSessionsReq struct {
+	g.Meta `path:"/users/{userID}/sessions" method:"get" auth:"true" tags:"Session Admin" summary:"List sessions of a user"`
+	UserID string `json:"userID" in:"path" v:"required" dc:"user id"`
+	Tenant string `json:"tenant" in:"query" dc:"tenant id, the tenant of the caller by default"`
+}
+
+type AdminListUserSessionsRes struct {
+	Sessions []*TokenInfo `json:"sessions" dc:"live sessions, sorted by expiry"`
+}
+
+// AdminRevokeUserSessionsReq revokes all sessions of a user
+type AdminRevokeUserSessionsReq struct {
+	g.Meta `path:"/users/{userID}/sessions" method:"delete" auth:"true" tags:"Session Admin" summary:"Revoke all sessions of a user"`
+	UserID string `json:"userID" in:"path" v:"required" dc:"user id"`
+	Tenant string `json:"tenant" in:"query" dc:"tenant id, the tenant of the caller by default"`
+}
+
+type AdminRevokeUserSessionsRes struct {
+	Revoked int `json:"revoked" dc:"number of revoked sessions"`
+}
+
+// AdminGetSessionReq gets a session by its token id
+type AdminGetSessionReq struct {
+	g.Meta  `path:"/sessions/{tokenID}" method:"get" auth:"true" tags:"Session Admin" summary:"Get a session"`
+	TokenID string `json:"tokenID" in:"path" v:"required" dc:"token id, TokenInfo.TokenID"`
+	Tenant  string `json:"tenant" in:"query" dc:"tenant id, the tenant of the caller by default"`
+}
+
+type AdminGetSessionRes struct {
+	*TokenInfo
+}
+
+// AdminRevokeSessionReq revokes a session by its token id
+type AdminRevokeSessionReq struct {
+	g.Meta  `path:"/sessions/{tokenID}" method:"delete" auth:"true" tags:"Session Admin" summary:"Revoke a session"`
+	TokenID string `json:"tokenID" in:"path" v:"required" dc:"token id, TokenInfo.TokenID"`
+	Tenant  string `json:"tenant" in:"query" dc:"tenant id, the tenant of the caller by default"`
+}
+
+type AdminRevokeSessionRes struct{}
+
+// MountAdmin binds the admin session API onto group after Init, so ops can inspect and kill sessions without access to the store:
+//
+//	GET    /stats                    count active sessions
+//	GET    /users/{userID}/sessions  list sessions of a user
+//	DELETE /users/{userID}/sessions  revoke all sessions of a user
+//	GET    /sessions/{tokenID}       get a session
+//	DELETE /sessions/{tokenID}       revoke a session
+//
+// Every request needs a token of GToken allowed by policy, e.g. gToken.PermissionPolicy("sessions:admin"),
+// regardless of PublicPaths. Sessions only manage their own tenant, "?tenant=" of another tenant is forbidden
+// unless allowed by WithCrossTenantPolicy. Responses are DefaultResponse, and the routes are documented in the
+// OpenAPI of the server. Revocations fire OnRevoke with reason admin_revoked.
+//
+// group may already use the middleware of GToken by UseMiddleware. A request authenticated by it is not validated again,
+// so validations are only counted, audited and traced once.
+//
+// /stats scans all tokens in the store, like Compact, so its count is cached for DefaultAdminStatsCacheTTL per tenant.
+//
+//	s.Group("/admin", func(group *ghttp.RouterGroup) {
+//	    err := gToken.MountAdmin(group, gToken.PermissionPolicy("sessions:admin"))
+//	})
+func (m *GToken) MountAdmin(group *ghttp.RouterGroup, policy Policy, opts ...AdminOption) error {
+	if m.current == nil {
+		return errors.New(errorNotInitialized)
+	}
+	if policy == nil {
+		return errors.New(errorAdminPolicyNotSet)
+	}
+	options := &adminOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	group.Middleware(adminResponse, m.adminAuth, m.adminPolicy(policy, options.crossTenant))
+	group.Bind(&adminController{m: m, stats: gcache.New()})
+	return nil
+}
+
+// AdminOption sets optional behaviors of MountAdmin
+type AdminOption func(options *adminOptions)
+
+type adminOptions struct {
+	crossTenant Policy
+}
+
+// WithCrossTenantPolicy lets sessions allowed by policy manage tenants other than their own.
+// The target tenant is in params as AdminParamTenant, e.g. a RulePolicy of
+//
+//	PolicyRule{Path: "/**", Claim: "tenants", Param: gtoken.AdminParamTenant, Op: gtoken.PolicyOpContains}
+func WithCrossTenantPolicy(policy Policy) AdminOption {
+	return func(options *adminOptions) {
+		options.crossTenant = policy
+	}
+}
+
+// adminAuth runs authMiddleware, unless the request is already authenticated by it in a parent group
+func (m *GToken) adminAuth(r *ghttp.Request) {
+	if authenticatedBy(r.Context()) == m {
+		r.Middleware.Next()
+		return
+	}
+	m.authMiddleware(r)
+}
+
+// adminPolicy only lets authenticated sessions allowed by policy go on, and checks the target tenant by crossTenant
+func (m *GToken) adminPolicy(policy Policy, crossTenant Policy) ghttp.HandlerFunc {
+	return func(r *ghttp.Request) {
+		tokenInfo, ok := FromContext(r.Context())
+		if !ok {
+			m.DoAfterAuth(r, false, nil)
+			return
+		}
+		var params map[string]string
+		if handler := r.GetServeHandler(); handler != nil {
+			params = handler.Values
+		}
+		allow, reason := policy.Evaluate(r.Request, params, tokenInfo)
+		if tenantID := r.Get(AdminParamTenant).String(); allow && tenantID != "" && tenantID != tokenInfo.TenantID {
+			allow, reason = false, ReasonCrossTenant
+			if crossTenant != nil {
+				// the target tenant is added to a copy of the route params
+				tenantParams := map[string]string{AdminParamTenant: tenantID}
+				for k, v := range params {
+					if k != AdminParamTenant {
+						tenantParams[k] = v
+					}
+				}
+				allow, reason = crossTenant.Evaluate(r.Request, tenantParams, tokenInfo)
+			}
+		}
+		if !allow {
+			m.DoForbidden(r, g.Map{"reason": reason})
+			return
+		}
+		r.Middleware.Next()
+	}
+}
+
+// adminResponse writes the result of admin handlers as DefaultResponse with the real http status
+func adminResponse(r *ghttp.Request) {
+	r.Middleware.Next()
+	// DoAfterAuth or DoForbidden has written the response
+	if r.Response.BufferLength() > 0 || r.Response.Writer.BytesWritten() > 0 {
+		return
+	}
+	res := DefaultResponse{Code: DefaultCodeOK, Data: r.GetHandlerResponse()}
+	if err := r.GetError(); err != nil {
+		status := http.StatusInternalServerError
+		res = DefaultResponse{Code: DefaultCodeInternalError, Msg: err.Error()}
+		switch gerror.Code(err) {
+		case gcode.CodeNotFound:
+			status, res.Code = http.StatusNotFound, DefaultCodeNotFound
+		case gcode.CodeValidationFailed, gcode.CodeInvalidParameter, gcode.CodeMissingParameter:
+			status, res.Code = http.StatusBadRequest, DefaultCodeBadRequest
+		}
+		r.Response.WriteHeader(status)
+	}
+	r.Response.WriteJson(res)
+}
+
+type adminController struct {
+	m     *GToken
+	stats *gcache.Cache // counts of /stats by key prefix of tenants
+}
+
+// view returns GToken of the tenant, which is the tenant of the caller if tenantID is empty
+func (c *adminController) view(ctx context.Context, tenantID string) (*GToken, error) {
+	if tenantID == "" {
+		if tokenInfo, ok := FromContext(ctx); ok {
+			tenantID = tokenInfo.TenantID
+		}
+	}
+	if tenantID == "" {
+		return c.m, nil
+	}
+	view, err := c.m.ForTenant(tenantID)
+	if err != nil {
+		return nil, gerror.NewCode(gcode.CodeNotFound, err.Error())
+	}
+	return view, nil
+}
+
+func (c *adminController) Stats(ctx context.Context, req *AdminStatsReq) (res *AdminStatsRes, err error) {
+	gt, err := c.view(ctx, req.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	count, err := c.stats.GetOrSetFuncLock(ctx, gt.keyPrefix, func(ctx context.Context) (any, error) {
+		tokens, err1 := gt.tokens(ctx)
+		if err1 != nil {
+			return nil, err1
+		}
+		return int64(len(tokens)), nil
+	}, DefaultAdminStatsCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminStatsRes{ActiveSessions: count.Int64(), Backend: c.m.backendName()}, nil
+}
+
+func (c *adminController) ListUserSessions(ctx context.Context, req *AdminListUserSessionsReq) (res *AdminListUserSessionsRes, err error) {
+	gt, err := c.view(ctx, req.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	tokenInfos, err := gt.UserSessions(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminListUserSessionsRes{Sessions: tokenInfos}, nil
+}
+
+func (c *adminController) RevokeUserSessions(ctx context.Context, req *AdminRevokeUserSessionsReq) (res *AdminRevokeUserSessionsRes, err error) {
+	gt, err := c.view(ctx, req.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := gt.RevokeUserSessions(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminRevokeUserSessionsRes{Revoked: revoked}, nil
+}
+
+func (c *adminController) GetSession(ctx context.Context, req *AdminGetSessionReq) (res *AdminGetSessionRes, err error) {
+	gt, err := c.view(ctx, req.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	tokenInfo, err := gt.Session(ctx, req.TokenID)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminGetSessionRes{TokenInfo: tokenInfo}, nil
+}
+
+func (c *adminController) RevokeSession(ctx context.Context, req *AdminRevokeSessionReq) (res *AdminRevokeSessionRes, err error) {
+	gt, err := c.view(ctx, req.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	if err = gt.RevokeSession(ctx, req.TokenID); err != nil {
+		return nil, err
+	}
+	return &AdminRevokeSessionRes{}, nil
+}
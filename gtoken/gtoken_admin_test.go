@@ -0,0 +1,271 @@
+package gtoken_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+func TestMountAdmin(t *testing.T) {
+	t.Log("test: admin session api")
+	ctx := context.Background()
+	var validations atomic.Int64
+	gToken := &gtoken.GToken{
+		RBAC: gtoken.NewRBAC().Grant("admin", "sessions:*"),
+		OnValidate: []gtoken.TokenListener{func(ctx context.Context, event gtoken.TokenEvent) {
+			validations.Add(1)
+		}},
+	}
+	if err := gToken.MountAdmin(nil, gToken.PermissionPolicy("sessions:admin")); err == nil {
+		t.Error("error: MountAdmin should fail before Init")
+	}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+
+	s := g.Server("admin")
+	s.SetPort(8089)
+	s.SetOpenApiPath("/api.json")
+	s.Group("/admin", func(group *ghttp.RouterGroup) {
+		if err := gToken.MountAdmin(group, nil); err == nil {
+			t.Error("error: MountAdmin should fail without policy")
+		}
+		if err := gToken.MountAdmin(group, gToken.PermissionPolicy("sessions:admin")); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// the admin API in a group which already uses the middleware
+	s.Group("/app", func(group *ghttp.RouterGroup) {
+		if err := gToken.UseMiddleware(ctx, group); err != nil {
+			t.Fatal(err)
+		}
+		group.Group("/admin", func(group *ghttp.RouterGroup) {
+			if err := gToken.MountAdmin(group, gToken.PermissionPolicy("sessions:admin")); err != nil {
+				t.Fatal(err)
+			}
+		})
+	})
+	err := s.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Shutdown()
+	}()
+
+	call := func(method string, path string, token string) (int, *gjson.Json) {
+		client := g.Client()
+		client.SetPrefix("http://127.0.0.1:8089")
+		if token != "" {
+			client.SetHeader("Authorization", "Bearer "+token)
+		}
+		res, err1 := client.DoRequest(ctx, method, path)
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		defer func() {
+			_ = res.Close()
+		}()
+		return res.StatusCode, gjson.New(res.ReadAllString())
+	}
+	newToken := func(userID string, roles ...string) (string, *gtoken.TokenInfo) {
+		token, tokenInfo, err1 := gToken.NewToken(ctx, userID, nil, gtoken.WithRoles(roles...))
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		return token, tokenInfo
+	}
+	adminToken, _ := newToken("admin-user", "admin")
+	defer func() {
+		_, _ = gToken.RemoveUserTokens(ctx, "admin-user")
+	}()
+	viewerToken, _ := newToken("viewer-user", "viewer")
+	defer func() {
+		_, _ = gToken.RemoveUserTokens(ctx, "viewer-user")
+	}()
+	userToken1, tokenInfo1 := newToken(userId)
+	_, tokenInfo2 := newToken(userId)
+
+	t.Log("1. only sessions allowed by the admin policy can call it")
+	// DoAfterAuth and DoForbidden write the response as usual
+	if _, res := call(http.MethodGet, "/admin/stats", ""); res.Get("code").Int() != gtoken.DefaultCodeUnauthorized {
+		t.Error("error: request without token should be 401, but:", res.String())
+	}
+	if _, res := call(http.MethodGet, "/admin/stats", viewerToken); res.Get("code").Int() != gtoken.DefaultCodeForbidden {
+		t.Error("error: request without permission should be 403, but:", res.String())
+	}
+	code, res := call(http.MethodGet, "/admin/stats", adminToken)
+	if code != http.StatusOK || res.Get("code").Int() != gtoken.DefaultCodeOK ||
+		res.Get("data.activeSessions").Int() < 4 || res.Get("data.backend").String() != "cache" {
+		t.Error("error: stats are not correct:", code, res.String())
+	}
+
+	t.Log("2. the count of stats is cached, and a token is validated once under the middleware")
+	activeSessions := res.Get("data.activeSessions").Int()
+	newToken("stats-user")
+	defer func() {
+		_, _ = gToken.RemoveUserTokens(ctx, "stats-user")
+	}()
+	if _, res = call(http.MethodGet, "/admin/stats", adminToken); res.Get("data.activeSessions").Int() != activeSessions {
+		t.Error("error: stats should be cached, but:", res.String())
+	}
+	validations.Store(0)
+	if code, res = call(http.MethodGet, "/app/admin/stats", adminToken); code != http.StatusOK || res.Get("code").Int() != gtoken.DefaultCodeOK {
+		t.Error("error: stats in the middleware group failed:", code, res.String())
+	}
+	if count := validations.Load(); count != 1 {
+		t.Error("error: token should be validated once, but:", count)
+	}
+
+	t.Log("3. sessions are listed and got by id")
+	code, res = call(http.MethodGet, "/admin/users/"+userId+"/sessions", adminToken)
+	if code != http.StatusOK || len(res.Get("data.sessions").Array()) != 2 ||
+		res.Get("data.sessions.0.tokenID").String() != tokenInfo1.TokenID {
+		t.Error("error: sessions are not correct:", code, res.String())
+	}
+	code, res = call(http.MethodGet, "/admin/sessions/"+tokenInfo2.TokenID, adminToken)
+	if code != http.StatusOK || res.Get("data.userID").String() != userId {
+		t.Error("error: session is not correct:", code, res.String())
+	}
+	code, res = call(http.MethodGet, "/admin/sessions/unknown", adminToken)
+	if code != http.StatusNotFound || res.Get("code").Int() != gtoken.DefaultCodeNotFound {
+		t.Error("error: unknown session should be 404, but:", code, res.String())
+	}
+	if _, res = call(http.MethodGet, "/admin/sessions/"+tokenInfo1.TokenID+"?tenant=unknown", adminToken); res.Get("code").Int() != gtoken.DefaultCodeForbidden ||
+		res.Get("data.reason").String() != gtoken.ReasonCrossTenant {
+		t.Error("error: another tenant should be 403, but:", res.String())
+	}
+
+	t.Log("4. a session is revoked")
+	if code, res = call(http.MethodDelete, "/admin/sessions/"+tokenInfo1.TokenID, adminToken); code != http.StatusOK {
+		t.Error("error: revoke failed:", code, res.String())
+	}
+	if _, err = gToken.ValidateToken(ctx, userToken1); err == nil {
+		t.Error("error: token should be revoked")
+	}
+	if code, _ = call(http.MethodDelete, "/admin/sessions/"+tokenInfo1.TokenID, adminToken); code != http.StatusNotFound {
+		t.Error("error: revoked session should be 404, but:", code)
+	}
+
+	t.Log("5. all sessions of a user are revoked")
+	code, res = call(http.MethodDelete, "/admin/users/"+userId+"/sessions", adminToken)
+	if code != http.StatusOK || res.Get("data.revoked").Int() != 1 {
+		t.Error("error: revoke all failed:", code, res.String())
+	}
+	code, res = call(http.MethodGet, "/admin/users/"+userId+"/sessions", adminToken)
+	if code != http.StatusOK || len(res.Get("data.sessions").Array()) != 0 {
+		t.Error("error: user should have no session:", code, res.String())
+	}
+
+	t.Log("6. routes are documented in openapi")
+	_, res = call(http.MethodGet, "/api.json", "")
+	for _, path := range []string{"/admin/stats", "/admin/users/{userID}/sessions", "/admin/sessions/{tokenID}"} {
+		if !res.Contains("paths." + path) {
+			t.Error("error: openapi should contain:", path)
+		}
+	}
+}
+
+func TestMountAdminTenants(t *testing.T) {
+	t.Log("test: admin session api only manages the tenant of the caller")
+	ctx := context.Background()
+	gToken := &gtoken.GToken{
+		Tenants: map[string]*gtoken.Tenant{
+			"admin-a": {SecretKey: []byte("admin-a-secret")},
+			"admin-b": {SecretKey: []byte("admin-b-secret")},
+		},
+		TenantResolver: gtoken.TenantFromHeader("X-Tenant-ID"),
+		RBAC:           gtoken.NewRBAC().Grant("admin", "sessions:*"),
+	}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	crossTenant, err := gtoken.NewRulePolicy(gtoken.PolicyRule{Path: "/**", Claim: "tenants", Param: gtoken.AdminParamTenant, Op: gtoken.PolicyOpContains})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := g.Server("admin-tenants")
+	s.SetPort(8090)
+	s.Group("/admin", func(group *ghttp.RouterGroup) {
+		if err1 := gToken.MountAdmin(group, gToken.PermissionPolicy("sessions:admin"), gtoken.WithCrossTenantPolicy(crossTenant)); err1 != nil {
+			t.Fatal(err1)
+		}
+	})
+	if err = s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Shutdown()
+	}()
+
+	call := func(method string, path string, tenantID string, token string) *gjson.Json {
+		client := g.Client()
+		client.SetPrefix("http://127.0.0.1:8090")
+		client.SetHeader("X-Tenant-ID", tenantID)
+		client.SetHeader("Authorization", "Bearer "+token)
+		res, err1 := client.DoRequest(ctx, method, path)
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		defer func() {
+			_ = res.Close()
+		}()
+		return gjson.New(res.ReadAllString())
+	}
+	newToken := func(tenantID string, userID string, extraData g.Map, roles ...string) string {
+		gt, err1 := gToken.ForTenant(tenantID)
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		token, _, err1 := gt.NewToken(ctx, userID, extraData, gtoken.WithRoles(roles...))
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		t.Cleanup(func() {
+			_, _ = gt.RemoveUserTokens(ctx, userID)
+		})
+		return token
+	}
+	adminA := newToken("admin-a", "admin-user", nil, "admin")
+	superA := newToken("admin-a", "super-user", g.Map{"tenants": []string{"admin-b"}}, "admin")
+	newToken("admin-a", "tenant-user", nil)
+	userB := newToken("admin-b", "tenant-user", nil)
+
+	t.Log("1. sessions of the tenant of the caller are managed by default")
+	if res := call(http.MethodGet, "/admin/users/tenant-user/sessions", "admin-a", adminA); len(res.Get("data.sessions").Array()) != 1 ||
+		res.Get("data.sessions.0.tenantID").String() != "admin-a" {
+		t.Error("error: sessions of the tenant are not correct:", res.String())
+	}
+	if res := call(http.MethodGet, "/admin/stats", "admin-a", adminA); res.Get("data.activeSessions").Int() != 3 {
+		t.Error("error: stats should only count the tenant of the caller:", res.String())
+	}
+
+	t.Log("2. another tenant is forbidden unless the cross-tenant policy allows it")
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		if res := call(method, "/admin/users/tenant-user/sessions?tenant=admin-b", "admin-a", adminA); res.Get("code").Int() != gtoken.DefaultCodeForbidden {
+			t.Error("error: another tenant should be forbidden:", method, res.String())
+		}
+	}
+	if res := call(http.MethodGet, "/admin/stats?tenant=admin-b", "admin-a", adminA); res.Get("code").Int() != gtoken.DefaultCodeForbidden {
+		t.Error("error: stats of another tenant should be forbidden:", res.String())
+	}
+	gtB, _ := gToken.ForTenant("admin-b")
+	if _, err = gtB.ValidateToken(ctx, userB); err != nil {
+		t.Error("error: session of another tenant should not be revoked:", err)
+	}
+
+	t.Log("3. another tenant is managed when the cross-tenant policy allows it")
+	if res := call(http.MethodDelete, "/admin/users/tenant-user/sessions?tenant=admin-b", "admin-a", superA); res.Get("data.revoked").Int() != 1 {
+		t.Error("error: session of another tenant should be revoked:", res.String())
+	}
+	if _, err = gtB.ValidateToken(ctx, userB); err == nil {
+		t.Error("error: session of another tenant should be revoked")
+	}
+}
@@ -0,0 +1,192 @@
+package gtoken
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+// AuditEvent is written to AuditWriter as one line of json
+type AuditEvent struct {
+	Time      string                 `json:"time"`
+	Event     string                 `json:"event"`            // AuditTokenIssued, AuditValidationFailed, AuditTokenRevoked or AuditTokenRefreshed
+	Reason    string                 `json:"reason,omitempty"` // why validation failed or a token is revoked
+	UserID    string                 `json:"userID,omitempty"`
+	TokenID   string                 `json:"tokenID,omitempty"`
+	TenantID  string                 `json:"tenantID,omitempty"`
+	ClientIP  string                 `json:"clientIP,omitempty"`
+	UserAgent string                 `json:"userAgent,omitempty"`
+	Method    string                 `json:"method,omitempty"`
+	Route     string                 `json:"route,omitempty"`
+	Params    map[string]interface{} `json:"params,omitempty"` // request params with sensitive values redacted
+}
+
+// auditLog serializes writes of audit events. It is shared by tenant views.
+type auditLog struct {
+	mu         sync.Mutex
+	writer     io.Writer
+	redactKeys []string
+}
+
+// NewAuditFile opens a file to append audit events, e.g. as AuditWriter
+func NewAuditFile(path string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+}
+
+func newAuditLog(writer io.Writer, redactKeys []string) *auditLog {
+	keys := make([]string, 0, len(redactKeys))
+	for _, key := range redactKeys {
+		keys = append(keys, strings.ToLower(key))
+	}
+	return &auditLog{writer: writer, redactKeys: keys}
+}
+
+func (a *auditLog) write(event *AuditEvent) error {
+	data, err := gjson.Encode(event)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.writer.Write(append(data, '\n'))
+	return err
+}
+
+// redact replaces values of sensitive keys, including nested ones in maps and slices
+func (a *auditLog) redact(params map[string]interface{}) map[string]interface{} {
+	if len(params) == 0 {
+		return nil
+	}
+	redacted := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if a.isSensitive(k) {
+			redacted[k] = RedactedValue
+		} else {
+			redacted[k] = a.redactValue(v)
+		}
+	}
+	return redacted
+}
+
+// redactValue redacts maps in a value, e.g. [{"password": "..."}] of a json body
+func (a *auditLog) redactValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return a.redact(value)
+	case []map[string]interface{}:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = a.redact(item)
+		}
+		return items
+	case []interface{}:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = a.redactValue(item)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+func (a *auditLog) isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range a.redactKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactParams redacts params by AuditRedactKeys, or DefaultAuditRedactKeys before Init
+func (m *GToken) redactParams(params map[string]interface{}) map[string]interface{} {
+	if m.audits != nil {
+		return m.audits.redact(params)
+	}
+	return newAuditLog(nil, DefaultAuditRedactKeys).redact(params)
+}
+
+// audit writes an event if AuditWriter is set. The request is taken from ctx if r is nil.
+func (m *GToken) audit(ctx context.Context, r *http.Request, event *AuditEvent) {
+	if m.audits == nil {
+		return
+	}
+	event.Time = time.Now().Format(time.RFC3339Nano)
+	if event.TenantID == "" {
+		event.TenantID = m.tenantID
+	}
+	if gr := ghttp.RequestFromCtx(ctx); gr != nil && (r == nil || gr.Request == r) {
+		event.ClientIP = gr.GetClientIp()
+		event.Params = m.audits.redact(gr.GetRequestMap())
+		r = gr.Request
+	} else if r != nil {
+		event.ClientIP = remoteIP(r)
+		if query := r.URL.Query(); len(query) > 0 {
+			params := make(map[string]interface{}, len(query))
+			for k, v := range query {
+				params[k] = strings.Join(v, ",")
+			}
+			event.Params = m.audits.redact(params)
+		}
+	}
+	if r != nil {
+		event.UserAgent = r.UserAgent()
+		event.Method = r.Method
+		event.Route = r.URL.Path
+	}
+	if err := m.audits.write(event); err != nil {
+		m.log(ctx, LogLevelError, errorWriteAudit, LogFieldError, err)
+	}
+}
+
+// auditToken writes the audit event of a lifecycle event. Successful validation is not audited.
+func (m *GToken) auditToken(ctx context.Context, event TokenEvent) {
+	var auditType string
+	switch event.Type {
+	case EventIssue:
+		auditType = AuditTokenIssued
+	case EventRefresh:
+		auditType = AuditTokenRefreshed
+	case EventRevoke:
+		auditType = AuditTokenRevoked
+	default:
+		return
+	}
+	m.audit(ctx, nil, &AuditEvent{
+		Event:    auditType,
+		Reason:   event.Reason,
+		UserID:   event.TokenInfo.UserID,
+		TokenID:  event.TokenInfo.TokenID,
+		TenantID: event.TokenInfo.TenantID,
+	})
+}
+
+// auditValidationFailed writes an AuditValidationFailed event
+func (m *GToken) auditValidationFailed(r *http.Request, reason string) {
+	m.audit(r.Context(), r, &AuditEvent{Event: AuditValidationFailed, Reason: reason})
+}
+
+// remoteIP returns the first X-Forwarded-For address, or the remote address of a plain *http.Request
+func remoteIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,131 @@
+package gtoken_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+func TestAuditLog(t *testing.T) {
+	t.Log("test: structured audit log")
+	ctx := context.Background()
+	buf := &bytes.Buffer{}
+	gToken := &gtoken.GToken{AuditWriter: buf}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	handler := gToken.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	readEvents := func() []gtoken.AuditEvent {
+		var events []gtoken.AuditEvent
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var event gtoken.AuditEvent
+			if err := gjson.DecodeTo(line, &event); err != nil {
+				t.Error("error: audit line is not json:", line)
+			}
+			events = append(events, event)
+		}
+		buf.Reset()
+		return events
+	}
+
+	t.Log("1. NewToken writes token_issued")
+	token, tokenInfo, err := gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := readEvents()
+	if len(events) != 1 || events[0].Event != gtoken.AuditTokenIssued || events[0].TokenID != tokenInfo.TokenID || events[0].Time == "" {
+		t.Error("error: token_issued is not correct:", events)
+	}
+
+	t.Log("2. failed validation writes request info with redacted params")
+	r := httptest.NewRequest(http.MethodGet, "/orders?password=123456&page=2", nil)
+	r.Header.Set("Authorization", gtoken.PrefixBearer+"invalid")
+	r.Header.Set("User-Agent", "audit-test")
+	r.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	events = readEvents()
+	if len(events) != 1 {
+		t.Fatal("error: validation_failed is not written:", events)
+	}
+	event := events[0]
+	if event.Event != gtoken.AuditValidationFailed || event.Reason == "" || event.ClientIP != "10.0.0.1" ||
+		event.UserAgent != "audit-test" || event.Method != http.MethodGet || event.Route != "/orders" {
+		t.Error("error: validation_failed is not correct:", event)
+	}
+	if event.Params["password"] != gtoken.RedactedValue || event.Params["page"] != "2" {
+		t.Error("error: params are not redacted:", event.Params)
+	}
+
+	t.Log("3. RemoveToken writes token_revoked")
+	if _, err = gToken.RemoveToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	events = readEvents()
+	if len(events) != 1 || events[0].Event != gtoken.AuditTokenRevoked || events[0].Reason != gtoken.ReasonTokenRevoked {
+		t.Error("error: token_revoked is not correct:", events)
+	}
+
+	t.Log("4. params in slices of json bodies are redacted")
+	s := g.Server("audit")
+	s.SetPort(8091)
+	s.Group("/", func(group *ghttp.RouterGroup) {
+		if err := gToken.UseMiddleware(ctx, group); err != nil {
+			t.Fatal(err)
+		}
+		group.POST("/orders", func(r *ghttp.Request) {})
+	})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Shutdown()
+	}()
+	client := g.Client()
+	client.SetHeader("Authorization", gtoken.PrefixBearer+"invalid")
+	res, err := client.ContentJson().Post(ctx, "http://127.0.0.1:8091/orders", g.Map{
+		"pwd":   "123456",
+		"items": g.Slice{g.Map{"name": "book", "password": "123456", "api_key": "key"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Close()
+	events = readEvents()
+	if len(events) != 1 {
+		t.Fatal("error: validation_failed is not written:", events)
+	}
+	params := gjson.New(events[0].Params)
+	if params.Get("pwd").String() != gtoken.RedactedValue || params.Get("items.0.name").String() != "book" ||
+		params.Get("items.0.password").String() != gtoken.RedactedValue || params.Get("items.0.api_key").String() != gtoken.RedactedValue {
+		t.Error("error: params are not redacted:", params.String())
+	}
+
+	t.Log("5. audit file")
+	path := filepath.Join(t.TempDir(), "audit", "gtoken.log")
+	file, err := gtoken.NewAuditFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fileToken := &gtoken.GToken{AuditWriter: file}
+	if !fileToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	if _, _, err = fileToken.NewToken(ctx, userId, nil); err != nil {
+		t.Fatal(err)
+	}
+	if content, err1 := os.ReadFile(path); err1 != nil || !strings.Contains(string(content), gtoken.AuditTokenIssued) {
+		t.Error("error: audit file is not written:", err1)
+	}
+}
@@ -3,8 +3,10 @@ package gtoken
 import (
 	"context"
 	"errors"
-	"fmt"
+	"hash/fnv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gogf/gf/v2/container/gset"
 	"github.com/gogf/gf/v2/container/gvar"
@@ -17,40 +19,128 @@ import (
 	"github.com/gogf/gf/v2/util/gconv"
 )
 
-func (m *GToken) setTokenCache(ctx context.Context, token string, tokenInfo *TokenInfo) (ok bool, err error) {
+// cacheToken returns the token used in cache keys.
+// The user index only keeps token IDs, and a token is rebuilt from its ID by encryptJWT.
+// A token with the "scope" claim differs from the rebuilt one, so it is normalized to the ID-only token.
+func (m *GToken) cacheToken(token string) (string, error) {
+	if !m.ScopeClaim {
+		return token, nil
+	}
+	id, err := m.decryptJWT(token)
+	if err != nil {
+		return "", err
+	}
+	return m.encryptJWT(id)
+}
+
+// tokenKey returns "jwt:{token}", prefixed by "tenant:{tenantID}:" in a tenant view
+func (m *GToken) tokenKey(token string) string {
+	return m.keyPrefix + DefaultPrefixToken + token
+}
+
+// userKey returns "user:{userID}", prefixed by "tenant:{tenantID}:" in a tenant view
+func (m *GToken) userKey(userID string) string {
+	return m.keyPrefix + DefaultPrefixUser + userID
+}
+
+// redis returns the injected redis client, or the one of RedisGroup. Every redis operation of GToken goes through it.
+func (m *GToken) redis() *gredis.Redis {
+	if m.Redis != nil {
+		return m.Redis
+	}
+	return g.Redis(m.RedisGroup)
+}
+
+// scanRedisKeys calls fn with every batch of keys matching a glob pattern, by SCAN instead of KEYS to not block redis
+func (m *GToken) scanRedisKeys(ctx context.Context, match string, fn func(keys []string) error) error {
+	cursor := "0"
+	for {
+		reply, err := m.redis().Do(ctx, "SCAN", cursor, "MATCH", match, "COUNT", 1000)
+		if err != nil {
+			return err
+		}
+		values := reply.Vars()
+		if len(values) != 2 {
+			return errors.New(errorUseRedis)
+		}
+		if err = fn(gconv.Strings(values[1].Val())); err != nil {
+			return err
+		}
+		if cursor = values[0].String(); cursor == "0" {
+			return nil
+		}
+	}
+}
+
+// userIndexLocks serialize changes of user indexes in gcache, which are read, changed and set again,
+// so an id added by NewToken is not lost by a concurrent Compact or RemoveToken. gcache is shared by the process, so are the locks.
+var userIndexLocks [64]sync.Mutex
+
+// lockUserIndex locks the user index of userKey in gcache, and returns the func to unlock it
+func lockUserIndex(userKey string) (unlock func()) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(userKey))
+	mu := &userIndexLocks[h.Sum32()%uint32(len(userIndexLocks))]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// setTokenCache stores a token for ttl, and keeps its user index for at least ExpireIn
+func (m *GToken) setTokenCache(ctx context.Context, token string, tokenInfo *TokenInfo, ttl time.Duration) (ok bool, err error) {
+	ok, err = m.putTokenCache(ctx, token, tokenInfo, ttl)
+	// keep file content up-to-date
+	if ok && m.CacheMode == CacheModeFile {
+		m.saveToFile(ctx)
+	}
+	return ok, err
+}
+
+// putTokenCache is setTokenCache without saving the file of file mode, so batches save it once
+func (m *GToken) putTokenCache(ctx context.Context, token string, tokenInfo *TokenInfo, ttl time.Duration) (ok bool, err error) {
+	ctx, done := m.traceStore(ctx, StoreOpSet)
+	defer func() {
+		done(err)
+	}()
 	/*
 		1. set key: "jwt:{token}",   value: tokenInfo
 		2. get the value of "user:{userId}" (format: []string of tokenId), check if each tokenId has expired, and reformat the slice
 		3. set key: "user:{userId}", value: the valid tokenId slice
 	*/
-	tokenKey := fmt.Sprintf("%s%s", DefaultPrefixToken, token)
-	userKey := fmt.Sprintf("%s%s", DefaultPrefixUser, tokenInfo.UserID)
+	token, err = m.cacheToken(token)
+	if err != nil {
+		return false, err
+	}
+	tokenKey := m.tokenKey(token)
+	userKey := m.userKey(tokenInfo.UserID)
+	indexTTL := max(ttl, m.settings().config.ExpireIn)
 	switch m.CacheMode {
 	case CacheModeCache, CacheModeFile:
 		// step 1: set token info
-		err = gcache.Set(ctx, tokenKey, tokenInfo, m.ExpireIn)
+		err = gcache.Set(ctx, tokenKey, tokenInfo, ttl)
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorSetCache, err), LogLevelError)
+			m.log(ctx, LogLevelError, errorSetCache, LogFieldError, err)
 			return false, err
 		}
 		// step 2: use userKey to get all token IDs as a set
 		// if tokens expire, remove their IDs from this set
+		unlock := lockUserIndex(userKey)
+		defer unlock()
 		tokenIdVar, err1 := gcache.Get(ctx, userKey)
 		if err1 != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorGetCache, err1), LogLevelError)
+			m.log(ctx, LogLevelError, errorGetCache, LogFieldError, err1)
 			return false, err1
 		}
 		tokenIdSlice := gconv.Strings(tokenIdVar.Val())
 		existedTokenIdSet := gset.NewStrSet()
 		for _, id := range tokenIdSlice {
-			jwtToken, err2 := encryptJWT(m.SecretKey, id)
+			jwtToken, err2 := m.encryptJWT(id)
 			if err2 != nil {
-				WriteLog(ctx, fmt.Sprintf("%s: %v", errorTokenEncrypt, err2), LogLevelError)
+				m.log(ctx, LogLevelError, errorTokenEncrypt, LogFieldError, err2)
 				return false, err2
 			}
-			idExists, err2 := gcache.Contains(ctx, jwtToken)
+			idExists, err2 := gcache.Contains(ctx, m.tokenKey(jwtToken))
 			if err2 != nil {
-				WriteLog(ctx, fmt.Sprintf("%s: %v", errorUseCache, err2), LogLevelError)
+				m.log(ctx, LogLevelError, errorUseCache, LogFieldError, err2)
 				return false, err2
 			}
 			if idExists {
@@ -59,66 +149,62 @@ func (m *GToken) setTokenCache(ctx context.Context, token string, tokenInfo *Tok
 		}
 		// step 3: add the new token into this set and refresh its ttl
 		existedTokenIdSet.Add(tokenInfo.TokenID)
-		err = gcache.Set(ctx, userKey, existedTokenIdSet.Slice(), m.ExpireIn)
+		err = gcache.Set(ctx, userKey, existedTokenIdSet.Slice(), indexTTL)
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorSetCache, err), LogLevelError)
+			m.log(ctx, LogLevelError, errorSetCache, LogFieldError, err)
 			return false, err
 		}
-		// keep file content up-to-date
-		if m.CacheMode == CacheModeFile {
-			saveToFile(ctx)
-		}
 	case CacheModeRedis:
 		cacheValueJson, err1 := gjson.Encode(tokenInfo)
 		if err1 != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorEncodeJson, err1), LogLevelError)
+			m.log(ctx, LogLevelError, errorEncodeJson, LogFieldError, err1)
 			return false, err1
 		}
 		// g.Redis().SetEx() only support ttl in seconds
 		// to make it the same as gcache which is in milliseconds, we use g.Redis().Set()
-		expireIn := m.ExpireIn.Milliseconds()
+		expireIn := ttl.Milliseconds()
 		// step 1: set token info
-		_, err = g.Redis().Set(ctx, tokenKey, cacheValueJson, gredis.SetOption{TTLOption: gredis.TTLOption{PX: &expireIn}})
+		_, err = m.redis().Set(ctx, tokenKey, cacheValueJson, gredis.SetOption{TTLOption: gredis.TTLOption{PX: &expireIn}})
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorSetCache, err), LogLevelError)
+			m.log(ctx, LogLevelError, errorSetCache, LogFieldError, err)
 			return false, err
 		}
 		// step 2: find all members which are the token IDs in redis set
 		// if tokens expire, remove their IDs from this set
-		tokenIdVar, err1 := g.Redis().SMembers(ctx, userKey)
+		tokenIdVar, err1 := m.redis().SMembers(ctx, userKey)
 		if err1 != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorUseRedis, err1), LogLevelError)
+			m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err1)
 			return false, err1
 		}
 		for _, id := range tokenIdVar.Strings() {
-			jwtToken, err2 := encryptJWT(m.SecretKey, id)
+			jwtToken, err2 := m.encryptJWT(id)
 			if err2 != nil {
-				WriteLog(ctx, fmt.Sprintf("%s: %v", errorTokenEncrypt, err2), LogLevelError)
+				m.log(ctx, LogLevelError, errorTokenEncrypt, LogFieldError, err2)
 				return false, err2
 			}
-			counts, err2 := g.Redis().Exists(ctx, jwtToken)
+			counts, err2 := m.redis().Exists(ctx, m.tokenKey(jwtToken))
 			if err2 != nil {
-				WriteLog(ctx, fmt.Sprintf("%s: %v", errorUseRedis, err2), LogLevelError)
+				m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err2)
 				return false, err2
 			}
 			if counts > 0 {
 				continue
 			}
-			_, err2 = g.Redis().SRem(ctx, userKey, id)
+			_, err2 = m.redis().SRem(ctx, userKey, id)
 			if err2 != nil {
-				WriteLog(ctx, fmt.Sprintf("%s: %v", errorUseRedis, err2), LogLevelError)
+				m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err2)
 				return false, err2
 			}
 		}
 		// step 3: add the new token into this set and refresh its ttl
-		_, err = g.Redis().SAdd(ctx, userKey, tokenInfo.TokenID)
+		_, err = m.redis().SAdd(ctx, userKey, tokenInfo.TokenID)
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorUseRedis, err), LogLevelError)
+			m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err)
 			return false, err
 		}
-		_, err = g.Redis().PExpire(ctx, userKey, expireIn)
+		_, err = m.redis().PExpire(ctx, userKey, indexTTL.Milliseconds())
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorUseRedis, err), LogLevelError)
+			m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err)
 			return false, err
 		}
 	default:
@@ -129,7 +215,15 @@ func (m *GToken) setTokenCache(ctx context.Context, token string, tokenInfo *Tok
 }
 
 func (m *GToken) getTokenCache(ctx context.Context, token string) (tokenInfo *TokenInfo, err error) {
-	tokenKey := fmt.Sprintf("%s%s", DefaultPrefixToken, token)
+	ctx, done := m.traceStore(ctx, StoreOpGet)
+	defer func() {
+		done(err)
+	}()
+	token, err = m.cacheToken(token)
+	if err != nil {
+		return nil, err
+	}
+	tokenKey := m.tokenKey(token)
 
 	var cacheValue *gvar.Var
 
@@ -137,12 +231,12 @@ func (m *GToken) getTokenCache(ctx context.Context, token string) (tokenInfo *To
 	case CacheModeCache, CacheModeFile:
 		cacheValue, err = gcache.Get(ctx, tokenKey)
 	case CacheModeRedis:
-		cacheValue, err = g.Redis().Get(ctx, tokenKey)
+		cacheValue, err = m.redis().Get(ctx, tokenKey)
 	default:
 		return nil, errors.New(errorInvalidMode)
 	}
 	if err != nil {
-		WriteLog(ctx, fmt.Sprintf("%s: %v", errorGetCache, err), LogLevelError)
+		m.log(ctx, LogLevelError, errorGetCache, LogFieldError, err)
 		return nil, err
 	}
 	if cacheValue.IsNil() {
@@ -151,7 +245,7 @@ func (m *GToken) getTokenCache(ctx context.Context, token string) (tokenInfo *To
 	tokenInfo = &TokenInfo{} // make sure to assign memory or tokenInfo is nil
 	err = cacheValue.Scan(tokenInfo)
 	if err != nil {
-		WriteLog(ctx, fmt.Sprintf("%s: %v", errorDecodeCache, err), LogLevelError)
+		m.log(ctx, LogLevelError, errorDecodeCache, LogFieldError, err)
 		return nil, err
 	}
 
@@ -159,43 +253,52 @@ func (m *GToken) getTokenCache(ctx context.Context, token string) (tokenInfo *To
 }
 
 func (m *GToken) refreshTokenCache(ctx context.Context, token string, tokenInfo *TokenInfo) (ok bool, err error) {
-	tokenKey := fmt.Sprintf("%s%s", DefaultPrefixToken, token)
-	userKey := fmt.Sprintf("%s%s", DefaultPrefixUser, tokenInfo.UserID)
+	ctx, done := m.traceStore(ctx, StoreOpRefresh)
+	defer func() {
+		done(err)
+	}()
+	token, err = m.cacheToken(token)
+	if err != nil {
+		return false, err
+	}
+	tokenKey := m.tokenKey(token)
+	userKey := m.userKey(tokenInfo.UserID)
+	ttl := m.settings().config.ExpireIn
 	switch m.CacheMode {
 	case CacheModeCache, CacheModeFile:
 		// set token info
-		err = gcache.Set(ctx, tokenKey, tokenInfo, m.ExpireIn)
+		err = gcache.Set(ctx, tokenKey, tokenInfo, ttl)
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorSetCache, err), LogLevelError)
+			m.log(ctx, LogLevelError, errorSetCache, LogFieldError, err)
 			return false, err
 		}
 		// refresh user key ttl
-		_, err = gcache.UpdateExpire(ctx, userKey, m.ExpireIn)
+		_, err = gcache.UpdateExpire(ctx, userKey, ttl)
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorSetCache, err), LogLevelError)
+			m.log(ctx, LogLevelError, errorSetCache, LogFieldError, err)
 			return false, err
 		}
 		// keep file content up-to-date
 		if m.CacheMode == CacheModeFile {
-			saveToFile(ctx)
+			m.saveToFile(ctx)
 		}
 	case CacheModeRedis:
 		cacheValueJson, err1 := gjson.Encode(tokenInfo)
 		if err1 != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorEncodeJson, err1), LogLevelError)
+			m.log(ctx, LogLevelError, errorEncodeJson, LogFieldError, err1)
 			return false, err1
 		}
-		expireIn := m.ExpireIn.Milliseconds()
+		expireIn := ttl.Milliseconds()
 		// set token info
-		_, err = g.Redis().Set(ctx, tokenKey, cacheValueJson, gredis.SetOption{TTLOption: gredis.TTLOption{PX: &expireIn}})
+		_, err = m.redis().Set(ctx, tokenKey, cacheValueJson, gredis.SetOption{TTLOption: gredis.TTLOption{PX: &expireIn}})
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorUseRedis, err), LogLevelError)
+			m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err)
 			return false, err
 		}
 		// token ID is not changed, so just refresh ttl
-		_, err = g.Redis().PExpire(ctx, userKey, expireIn)
+		_, err = m.redis().PExpire(ctx, userKey, expireIn)
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorUseRedis, err), LogLevelError)
+			m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err)
 			return false, err
 		}
 	default:
@@ -206,6 +309,10 @@ func (m *GToken) refreshTokenCache(ctx context.Context, token string, tokenInfo
 }
 
 func (m *GToken) removeTokenCache(ctx context.Context, token string) (ok bool, err error) {
+	ctx, done := m.traceStore(ctx, StoreOpRemove)
+	defer func() {
+		done(err)
+	}()
 	/*
 		1. get tokenInfo by token
 		2. remove token
@@ -215,21 +322,27 @@ func (m *GToken) removeTokenCache(ctx context.Context, token string) (ok bool, e
 	if err != nil {
 		return false, err
 	}
-	tokenKey := fmt.Sprintf("%s%s", DefaultPrefixToken, token)
-	userKey := fmt.Sprintf("%s%s", DefaultPrefixUser, tokenInfo.UserID)
+	token, err = m.cacheToken(token)
+	if err != nil {
+		return false, err
+	}
+	tokenKey := m.tokenKey(token)
+	userKey := m.userKey(tokenInfo.UserID)
 
 	switch m.CacheMode {
 	case CacheModeCache, CacheModeFile:
 		// remove token
 		_, err = gcache.Remove(ctx, tokenKey)
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorDeleteCache, err), LogLevelError)
+			m.log(ctx, LogLevelError, errorDeleteCache, LogFieldError, err)
 			return false, err
 		}
 		// remove token id from userKey
+		unlock := lockUserIndex(userKey)
+		defer unlock()
 		tokenIdVar, err1 := gcache.Get(ctx, userKey)
 		if err1 != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorGetCache, err1), LogLevelError)
+			m.log(ctx, LogLevelError, errorGetCache, LogFieldError, err1)
 			return false, err1
 		}
 		tokenIdSlice := gconv.Strings(tokenIdVar.Val())
@@ -238,31 +351,31 @@ func (m *GToken) removeTokenCache(ctx context.Context, token string) (ok bool, e
 		if existedTokenIdSet.Size() == 0 {
 			_, err = gcache.Remove(ctx, userKey)
 			if err != nil {
-				WriteLog(ctx, fmt.Sprintf("%s: %v", errorDeleteCache, err), LogLevelError)
+				m.log(ctx, LogLevelError, errorDeleteCache, LogFieldError, err)
 				return false, err
 			}
 		} else {
-			err = gcache.Set(ctx, userKey, existedTokenIdSet.Slice(), m.ExpireIn) // maybe it's OK to not use the real ttl here
+			err = gcache.Set(ctx, userKey, existedTokenIdSet.Slice(), m.settings().config.ExpireIn) // maybe it's OK to not use the real ttl here
 			if err != nil {
-				WriteLog(ctx, fmt.Sprintf("%s: %v", errorSetCache, err), LogLevelError)
+				m.log(ctx, LogLevelError, errorSetCache, LogFieldError, err)
 				return false, err
 			}
 		}
 		// keep file content up-to-date
 		if m.CacheMode == CacheModeFile {
-			saveToFile(ctx)
+			m.saveToFile(ctx)
 		}
 	case CacheModeRedis:
 		// remove token
-		_, err = g.Redis().Del(ctx, tokenKey)
+		_, err = m.redis().Del(ctx, tokenKey)
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorDeleteCache, err), LogLevelError)
+			m.log(ctx, LogLevelError, errorDeleteCache, LogFieldError, err)
 			return false, err
 		}
 		// remove token id from user key
-		_, err = g.Redis().SRem(ctx, userKey, tokenInfo.TokenID)
+		_, err = m.redis().SRem(ctx, userKey, tokenInfo.TokenID)
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorDeleteCache, err), LogLevelError)
+			m.log(ctx, LogLevelError, errorDeleteCache, LogFieldError, err)
 			return false, err
 		}
 	default:
@@ -272,89 +385,134 @@ func (m *GToken) removeTokenCache(ctx context.Context, token string) (ok bool, e
 	return true, nil
 }
 
-func (m *GToken) removeUserCache(ctx context.Context, userId string) (ok bool, err error) {
-	userKey := fmt.Sprintf("%s%s", DefaultPrefixUser, userId)
+// removeUserCache removes all tokens of a user, and returns the removed ones
+func (m *GToken) removeUserCache(ctx context.Context, userId string) (tokenInfos []*TokenInfo, ok bool, err error) {
+	ctx, done := m.traceStore(ctx, StoreOpRemoveUser)
+	defer func() {
+		done(err)
+	}()
+	userKey := m.userKey(userId)
 	switch m.CacheMode {
 	case CacheModeCache, CacheModeFile:
 		// get cached value
+		unlock := lockUserIndex(userKey)
+		defer unlock()
 		tokenIdVar, err1 := gcache.Get(ctx, userKey)
 		if err1 != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorDeleteCache, err), LogLevelError)
-			return false, err1
+			m.log(ctx, LogLevelError, errorGetCache, LogFieldError, err1)
+			return nil, false, err1
 		}
 		// remove userKey before removing every token to avoid some error cases
 		_, err = gcache.Remove(ctx, userKey)
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorDeleteCache, err), LogLevelError)
-			return false, err
+			m.log(ctx, LogLevelError, errorDeleteCache, LogFieldError, err)
+			return nil, false, err
 		}
 		tokenIdSlice := gconv.Strings(tokenIdVar.Val())
 		// remove related tokens
 		for _, id := range tokenIdSlice {
-			jwtToken, err2 := encryptJWT(m.SecretKey, id)
+			jwtToken, err2 := m.encryptJWT(id)
 			if err2 != nil {
-				WriteLog(ctx, fmt.Sprintf("%s: %v", errorTokenEncrypt, err2), LogLevelError)
-				return false, err2
+				m.log(ctx, LogLevelError, errorTokenEncrypt, LogFieldError, err2)
+				return nil, false, err2
 			}
-			tokenKey := fmt.Sprintf("%s%s", DefaultPrefixToken, jwtToken)
+			tokenInfos = append(tokenInfos, m.removedTokenInfo(ctx, jwtToken, userId, id))
+			tokenKey := m.tokenKey(jwtToken)
 			_, err = gcache.Remove(ctx, tokenKey)
 			if err != nil {
-				WriteLog(ctx, fmt.Sprintf("%s: %v", errorDeleteCache, err), LogLevelError)
-				return false, err
+				m.log(ctx, LogLevelError, errorDeleteCache, LogFieldError, err)
+				return nil, false, err
 			}
 		}
 		// keep file content up-to-date
 		if m.CacheMode == CacheModeFile {
-			saveToFile(ctx)
+			m.saveToFile(ctx)
 		}
 	case CacheModeRedis:
-		// get cached value
-		tokenIdVar, err1 := g.Redis().Get(ctx, userKey)
+		// get token IDs of the redis set
+		tokenIdVar, err1 := m.redis().SMembers(ctx, userKey)
 		if err1 != nil {
-			return false, err1
+			m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err1)
+			return nil, false, err1
 		}
 		// remove userKey before removing every token to avoid some error cases
-		_, err = g.Redis().Del(ctx, userKey)
+		_, err = m.redis().Del(ctx, userKey)
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorDeleteCache, err), LogLevelError)
-			return false, err
+			m.log(ctx, LogLevelError, errorDeleteCache, LogFieldError, err)
+			return nil, false, err
 		}
-		tokenIdSlice := gconv.Strings(tokenIdVar.Val())
+		tokenIdSlice := tokenIdVar.Strings()
 		// remove related token
 		for _, id := range tokenIdSlice {
-			jwtToken, err2 := encryptJWT(m.SecretKey, id)
+			jwtToken, err2 := m.encryptJWT(id)
 			if err2 != nil {
-				WriteLog(ctx, fmt.Sprintf("%s: %v", errorTokenEncrypt, err2), LogLevelError)
-				return false, err2
+				m.log(ctx, LogLevelError, errorTokenEncrypt, LogFieldError, err2)
+				return nil, false, err2
 			}
-			tokenKey := fmt.Sprintf("%s%s", DefaultPrefixToken, jwtToken)
-			_, err = g.Redis().Del(ctx, tokenKey)
+			tokenInfos = append(tokenInfos, m.removedTokenInfo(ctx, jwtToken, userId, id))
+			tokenKey := m.tokenKey(jwtToken)
+			_, err = m.redis().Del(ctx, tokenKey)
 			if err != nil {
-				WriteLog(ctx, fmt.Sprintf("%s: %v", errorDeleteCache, err), LogLevelError)
-				return false, err
+				m.log(ctx, LogLevelError, errorDeleteCache, LogFieldError, err)
+				return nil, false, err
 			}
 		}
 
 	default:
-		return false, errors.New(errorInvalidMode)
+		return nil, false, errors.New(errorInvalidMode)
 	}
 
-	return true, nil
+	return tokenInfos, true, nil
 }
 
-func saveToFile(ctx context.Context) {
+// userTokenIDs returns the token IDs in the index of a user, which may include IDs of expired tokens
+func (m *GToken) userTokenIDs(ctx context.Context, userID string) (ids []string, err error) {
+	ctx, done := m.traceStore(ctx, StoreOpGetUser)
+	defer func() {
+		done(err)
+	}()
+	userKey := m.userKey(userID)
+	switch m.CacheMode {
+	case CacheModeCache, CacheModeFile:
+		tokenIdVar, err1 := gcache.Get(ctx, userKey)
+		if err1 != nil {
+			m.log(ctx, LogLevelError, errorGetCache, LogFieldError, err1)
+			return nil, err1
+		}
+		return gconv.Strings(tokenIdVar.Val()), nil
+	case CacheModeRedis:
+		tokenIdVar, err1 := m.redis().SMembers(ctx, userKey)
+		if err1 != nil {
+			m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err1)
+			return nil, err1
+		}
+		return tokenIdVar.Strings(), nil
+	default:
+		return nil, errors.New(errorInvalidMode)
+	}
+}
+
+// removedTokenInfo gets the info of a token to be removed. An expired token only has its ids.
+func (m *GToken) removedTokenInfo(ctx context.Context, token string, userID string, tokenID string) *TokenInfo {
+	if tokenInfo, err := m.getTokenCache(ctx, token); err == nil {
+		return tokenInfo
+	}
+	return &TokenInfo{UserID: userID, TokenID: tokenID, TenantID: m.tenantID}
+}
+
+func (m *GToken) saveToFile(ctx context.Context) {
 	file := gfile.Temp(CacheModeFileDat)
 	data, err := gcache.Data(ctx)
 	if err != nil {
-		WriteLog(ctx, fmt.Sprintf("%s: %v", errorGetCache, err), LogLevelError)
+		m.log(ctx, LogLevelError, errorGetCache, LogFieldError, err)
 	}
 	err = gfile.PutContents(file, gjson.New(data).MustToJsonString())
 	if err != nil {
-		WriteLog(ctx, fmt.Sprintf("%s: %v", errorWriteFile, err), LogLevelError)
+		m.log(ctx, LogLevelError, errorWriteFile, LogFieldError, err)
 	}
 }
 
-func initCacheModeFile(ctx context.Context) {
+func (m *GToken) initCacheModeFile(ctx context.Context) {
 	file := gfile.Temp(CacheModeFileDat)
 	if !gfile.Exists(file) {
 		return
@@ -364,10 +522,13 @@ func initCacheModeFile(ctx context.Context) {
 	if maps == nil || len(maps) <= 0 {
 		return
 	}
+	// user indexes are rebuilt from the loaded tokens, and kept until the last of them expires
+	userTokenIDs := make(map[string][]string)
+	userTTLs := make(map[string]time.Duration)
 	for k, v := range maps {
 		// Avoid using m.ExpireIn
 		// Since loading tokens from files, the interval should not be reset
-		if !strings.HasPrefix(k, DefaultPrefixToken) {
+		if !isTokenKey(k) {
 			continue
 		}
 		var token TokenInfo
@@ -381,7 +542,36 @@ func initCacheModeFile(ctx context.Context) {
 		}
 		err = gcache.Set(ctx, k, v, expireIn)
 		if err != nil {
-			WriteLog(ctx, fmt.Sprintf("%s: %v", errorSetCache, err), LogLevelError)
+			m.log(ctx, LogLevelError, errorSetCache, LogFieldError, err)
+			continue
 		}
+		prefix, _ := splitKeyPrefix(k)
+		userKey := prefix + DefaultPrefixUser + token.UserID
+		userTokenIDs[userKey] = append(userTokenIDs[userKey], token.TokenID)
+		userTTLs[userKey] = max(userTTLs[userKey], expireIn)
+	}
+	for userKey, ids := range userTokenIDs {
+		err := gcache.Set(ctx, userKey, ids, userTTLs[userKey])
+		if err != nil {
+			m.log(ctx, LogLevelError, errorSetCache, LogFieldError, err)
+		}
+	}
+}
+
+// isTokenKey reports whether a cache key is "jwt:{token}" or "tenant:{tenantID}:jwt:{token}"
+func isTokenKey(key string) bool {
+	_, key = splitKeyPrefix(key)
+	return strings.HasPrefix(key, DefaultPrefixToken)
+}
+
+// splitKeyPrefix splits a cache key into the prefix "tenant:{tenantID}:" of its tenant view, empty for GToken, and the rest
+func splitKeyPrefix(key string) (prefix string, rest string) {
+	if !strings.HasPrefix(key, DefaultPrefixTenant) {
+		return "", key
+	}
+	index := strings.Index(key[len(DefaultPrefixTenant):], ":")
+	if index < 0 {
+		return "", key
 	}
+	return key[:len(DefaultPrefixTenant)+index+1], key[len(DefaultPrefixTenant)+index+1:]
 }
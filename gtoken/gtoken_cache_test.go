@@ -0,0 +1,83 @@
+package gtoken_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+// listenRedis starts a listener which counts connections and closes them, so a store call fails fast
+func listenRedis(t *testing.T) (address string, connections *atomic.Int32) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	connections = &atomic.Int32{}
+	go func() {
+		for {
+			conn, err1 := listener.Accept()
+			if err1 != nil {
+				return
+			}
+			connections.Add(1)
+			_ = conn.Close()
+		}
+	}()
+	return listener.Addr().String(), connections
+}
+
+func TestRedisClient(t *testing.T) {
+	t.Log("test: store operations use the selected redis client")
+	ctx := context.Background()
+	// the default group must never be used by the cases below
+	defaultAddress, defaultConnections := listenRedis(t)
+	gredis.SetConfig(&gredis.Config{Address: defaultAddress})
+	t.Cleanup(gredis.ClearConfig)
+
+	t.Log("1. an injected client")
+	address, connections := listenRedis(t)
+	redis, err := gredis.New(&gredis.Config{Address: address})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gToken := &gtoken.GToken{CacheMode: gtoken.CacheModeRedis, Redis: redis}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	if _, _, err = gToken.NewToken(ctx, userId, nil); err == nil {
+		t.Error("error: NewToken should fail on a closed connection")
+	}
+	if _, err = gToken.RemoveUserTokens(ctx, userId); err == nil {
+		t.Error("error: RemoveUserTokens should fail on a closed connection")
+	}
+	if connections.Load() == 0 {
+		t.Error("error: injected client is not used")
+	}
+
+	t.Log("2. a redis group")
+	address, connections = listenRedis(t)
+	gredis.SetConfig(&gredis.Config{Address: address}, "sessions")
+	gToken = &gtoken.GToken{CacheMode: gtoken.CacheModeRedis, RedisGroup: "sessions"}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	if _, err = gToken.ValidateToken(ctx, "invalid"); err == nil {
+		t.Error("error: invalid token should fail")
+	}
+	if _, _, err = gToken.NewToken(ctx, userId, nil); err == nil {
+		t.Error("error: NewToken should fail on a closed connection")
+	}
+	if connections.Load() == 0 {
+		t.Error("error: client of the redis group is not used")
+	}
+	if defaultConnections.Load() != 0 {
+		t.Error("error: default redis group should not be used")
+	}
+}
@@ -0,0 +1,234 @@
+package gtoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/v2/container/gvar"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config holds the settings of GToken which can be loaded from the GoFrame config, see NewFromConfig and Reload.
+// SecretKey, Algorithm, CacheMode and RedisGroup are fixed after Init, the others can be reloaded.
+type Config struct {
+	SecretKey        []byte
+	Algorithm        string
+	ExpireIn         time.Duration
+	TokenIDLength    uint8
+	SingleSession    bool
+	AutoRefreshToken bool
+	RejectInvalid    bool
+	PublicPaths      []string
+	OptionalPaths    []string
+	PublicMethods    []string
+	CacheMode        uint8
+	RedisGroup       string
+
+	storeMode bool // whether "store.mode" is present, set by LoadConfig
+}
+
+// NewFromConfig builds a GToken from a section of the GoFrame config. All keys are optional:
+//
+//	auth:
+//	  secretKey: "a-long-random-secret"  # the default one is only for development
+//	  algorithm: HS256                    # HS256, HS384 or HS512
+//	  expireIn: 24h                       # duration string, e.g. "30m", "24h" or "7d"
+//	  tokenIDLength: 12
+//	  singleSession: false
+//	  autoRefreshToken: false
+//	  publicPaths: ["POST:/login", "/hello"]
+//	  optionalPaths: ["GET:/articles/*"]
+//	  publicMethods: ["/pkg.Service/Login"]
+//	  rejectInvalid: false
+//	  store:
+//	    mode: cache                       # cache, redis or file
+//	    redisGroup: default               # group of the "redis" config used by mode redis
+//
+// Keys are matched case-insensitively, so "secretkey" and "SecretKey" work as well. Unknown keys are logged as warnings
+// and ignored. "cacheMode" of earlier versions is read as store.mode unless store.mode is set.
+// A bad value is returned as an error naming its key. Hooks, RBAC, Policy and other funcs are set on the result in code.
+func NewFromConfig(ctx context.Context, pattern string) (*GToken, error) {
+	cfg, err := LoadConfig(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+	m := &GToken{}
+	cfg.apply(m)
+	return m, nil
+}
+
+// LoadConfig reads a section of the GoFrame config in the format of NewFromConfig, e.g. to pass it to Reload
+func LoadConfig(ctx context.Context, pattern string) (*Config, error) {
+	section, err := g.Cfg().Get(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if section.IsNil() {
+		return nil, fmt.Errorf("%s: %q", errorConfigNotFound, pattern)
+	}
+	if !section.IsMap() {
+		return nil, configError(pattern, "", "should be a map")
+	}
+	return newConfigFromMap(ctx, pattern, section.MapStrVar())
+}
+
+func newConfigFromMap(ctx context.Context, pattern string, values map[string]*gvar.Var) (c *Config, err error) {
+	c = &Config{}
+	var legacyMode *gvar.Var
+	for key, value := range values {
+		switch strings.ToLower(key) {
+		case "secretkey":
+			c.SecretKey = []byte(value.String())
+		case "algorithm":
+			c.Algorithm = strings.ToUpper(value.String())
+			if _, ok := jwt.GetSigningMethod(c.Algorithm).(*jwt.SigningMethodHMAC); !ok {
+				return nil, configError(pattern, key, errorInvalidAlgorithm)
+			}
+		case "expirein":
+			if c.ExpireIn, err = configDuration(value); err != nil {
+				return nil, configError(pattern, key, err.Error())
+			}
+		case "tokenidlength":
+			length := value.Int()
+			if length < 2 || length > 255 {
+				return nil, configError(pattern, key, "should be between 2 and 255")
+			}
+			c.TokenIDLength = uint8(length)
+		case "singlesession":
+			c.SingleSession = value.Bool()
+		case "autorefreshtoken":
+			c.AutoRefreshToken = value.Bool()
+		case "rejectinvalid":
+			c.RejectInvalid = value.Bool()
+		case "publicpaths", "optionalpaths", "publicmethods":
+			if !value.IsNil() && !value.IsSlice() {
+				return nil, configError(pattern, key, "should be a list, e.g. [\"POST:/login\", \"/hello\"]")
+			}
+			rules := value.Strings()
+			if _, err = newPathMatcher(rules); err != nil {
+				return nil, configError(pattern, key, err.Error())
+			}
+			switch strings.ToLower(key) {
+			case "publicpaths":
+				c.PublicPaths = rules
+			case "optionalpaths":
+				c.OptionalPaths = rules
+			default:
+				c.PublicMethods = rules
+			}
+		case "store":
+			if !value.IsMap() {
+				return nil, configError(pattern, key, "should be a map")
+			}
+			if err = c.configStore(ctx, pattern, key, value.MapStrVar()); err != nil {
+				return nil, err
+			}
+		case "cachemode":
+			// the key of earlier versions, applied after the loop so store.mode always wins
+			defaultLogger.Log(ctx, LogLevelWarning, warnDeprecatedConfigKey, "key", pattern+"."+key, "use", pattern+".store.mode")
+			legacyMode = value
+		default:
+			defaultLogger.Log(ctx, LogLevelWarning, warnUnknownConfigKey, "key", pattern+"."+key)
+		}
+	}
+	if legacyMode != nil && !c.storeMode {
+		if c.CacheMode, err = configCacheMode(legacyMode); err != nil {
+			return nil, configError(pattern, "cacheMode", err.Error())
+		}
+	}
+	if c.RedisGroup != "" && c.CacheMode != CacheModeRedis {
+		return nil, configError(pattern, "store.redisGroup", "is only used by mode redis")
+	}
+	return c, nil
+}
+
+func (c *Config) configStore(ctx context.Context, pattern string, storeKey string, values map[string]*gvar.Var) (err error) {
+	for key, value := range values {
+		switch strings.ToLower(key) {
+		case "mode":
+			c.storeMode = true
+			if c.CacheMode, err = configCacheMode(value); err != nil {
+				return configError(pattern, storeKey+"."+key, err.Error())
+			}
+		case "redisgroup":
+			c.RedisGroup = value.String()
+		default:
+			defaultLogger.Log(ctx, LogLevelWarning, warnUnknownConfigKey, "key", pattern+"."+storeKey+"."+key)
+		}
+	}
+	return nil
+}
+
+// configCacheMode parses a cache mode by its name or number
+func configCacheMode(value *gvar.Var) (uint8, error) {
+	switch strings.ToLower(value.String()) {
+	case "cache", "0":
+		return CacheModeCache, nil
+	case "redis", "1":
+		return CacheModeRedis, nil
+	case "file", "2":
+		return CacheModeFile, nil
+	default:
+		return 0, errors.New("should be cache, redis or file")
+	}
+}
+
+// withDefaults returns a copy of Config with the defaults of Init filled in
+func (c Config) withDefaults() Config {
+	if c.ExpireIn == 0 {
+		c.ExpireIn = DefaultExpireIn
+	}
+	if len(c.SecretKey) == 0 {
+		c.SecretKey = []byte(DefaultSecretKey)
+	}
+	if c.TokenIDLength == 0 {
+		c.TokenIDLength = DefaultTokenIDLength
+	}
+	if c.Algorithm == "" {
+		c.Algorithm = DefaultAlgorithm
+	}
+	return c
+}
+
+// apply sets the fields of GToken by Config
+func (c *Config) apply(m *GToken) {
+	m.SecretKey = c.SecretKey
+	m.Algorithm = c.Algorithm
+	m.ExpireIn = c.ExpireIn
+	m.TokenIDLength = c.TokenIDLength
+	m.SingleSession = c.SingleSession
+	m.AutoRefreshToken = c.AutoRefreshToken
+	m.RejectInvalid = c.RejectInvalid
+	m.PublicPaths = c.PublicPaths
+	m.OptionalPaths = c.OptionalPaths
+	m.PublicMethods = c.PublicMethods
+	m.CacheMode = c.CacheMode
+	m.RedisGroup = c.RedisGroup
+}
+
+// configDuration parses a duration string like "30m", "24h" or "7d"
+func configDuration(value *gvar.Var) (time.Duration, error) {
+	if _, ok := value.Val().(string); !ok {
+		return 0, fmt.Errorf("should be a duration string like \"24h\", but got %v", value.Val())
+	}
+	duration, err := gtime.ParseDuration(value.String())
+	if err != nil {
+		return 0, err
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("should be positive, but got %s", value.String())
+	}
+	return duration, nil
+}
+
+func configError(pattern string, key string, detail string) error {
+	if key == "" {
+		return fmt.Errorf("%s %s: %s", errorInvalidConfig, pattern, detail)
+	}
+	return fmt.Errorf("%s %s.%s: %s", errorInvalidConfig, pattern, key, detail)
+}
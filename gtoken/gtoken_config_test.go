@@ -0,0 +1,121 @@
+package gtoken_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcfg"
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+// configAdapter is the config adapter of g.Cfg() in tests. It is set once by TestMain before any test starts,
+// since gcfg.Config.SetAdapter races with servers and config watchers reading the adapter in other goroutines.
+var configAdapter *gcfg.AdapterContent
+
+func TestMain(m *testing.M) {
+	adapter, err := gcfg.NewAdapterContent()
+	if err != nil {
+		panic(err)
+	}
+	configAdapter = adapter
+	g.Cfg().SetAdapter(configAdapter)
+	os.Exit(m.Run())
+}
+
+// useConfig sets the content of the config adapter of g.Cfg() until the test ends
+func useConfig(t *testing.T, content string) *gcfg.AdapterContent {
+	if err := configAdapter.SetContent(content); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = configAdapter.SetContent("")
+	})
+	return configAdapter
+}
+
+func TestNewFromConfig(t *testing.T) {
+	t.Log("test: build GToken from config")
+	ctx := context.Background()
+
+	t.Log("1. a full section")
+	useConfig(t, `
+auth:
+  secretKey: "config-secret"
+  algorithm: hs512
+  expireIn: 2h
+  tokenIDLength: 16
+  singleSession: true
+  publicPaths: ["POST:/login", "/hello"]
+  optionalPaths: ["GET:/articles/*"]
+  store:
+    mode: redis
+    redisGroup: auth
+`)
+	gToken, err := gtoken.NewFromConfig(ctx, "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gToken.SecretKey) != "config-secret" || gToken.Algorithm != "HS512" || gToken.ExpireIn != 2*time.Hour ||
+		gToken.TokenIDLength != 16 || !gToken.SingleSession || len(gToken.PublicPaths) != 2 || len(gToken.OptionalPaths) != 1 ||
+		gToken.CacheMode != gtoken.CacheModeRedis || gToken.RedisGroup != "auth" {
+		t.Error("error: GToken is not correct:", gToken)
+	}
+
+	t.Log("2. HS512 tokens work after Init")
+	useConfig(t, "auth:\n  algorithm: HS512\n  expireIn: 7d\n")
+	if gToken, err = gtoken.NewFromConfig(ctx, "auth"); err != nil {
+		t.Fatal(err)
+	}
+	if gToken.ExpireIn != 7*24*time.Hour {
+		t.Error("error: expireIn should be 7 days, but:", gToken.ExpireIn)
+	}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	token, _, err := gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = gToken.ValidateToken(ctx, token); err != nil {
+		t.Error("error:", err)
+	}
+	if _, err = gToken.RemoveToken(ctx, token); err != nil {
+		t.Error("error:", err)
+	}
+
+	t.Log("3. bad values name their keys")
+	badConfigs := map[string]string{
+		"auth:\n  expireIn: 3600\n":               "auth.expireIn",
+		"auth:\n  expireIn: -1h\n":                "auth.expireIn",
+		"auth:\n  algorithm: RS256\n":             "auth.algorithm",
+		"auth:\n  publicPaths: \"/a,/b\"\n":       "auth.publicPaths",
+		"auth:\n  publicPaths: [\"~(\"]\n":        "auth.publicPaths",
+		"auth:\n  store:\n    mode: memcached\n":  "auth.store.mode",
+		"auth:\n  store:\n    redisGroup: auth\n": "auth.store.redisGroup",
+		"auth:\n  cacheMode: memcached\n":         "auth.cacheMode",
+		"auth:\n  tokenIDLength: 1\n":             "auth.tokenIDLength",
+		"other:\n  expireIn: 1h\n":                "config section not found",
+	}
+	for content, key := range badConfigs {
+		useConfig(t, content)
+		if _, err = gtoken.NewFromConfig(ctx, "auth"); err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("error: config %q should fail on %s, but: %v", content, key, err)
+		}
+	}
+	t.Log("4. keys are case-insensitive, unknown keys are ignored, and cacheMode of earlier versions is read")
+	useConfig(t, "auth:\n  SecretKey: case-secret\n  expirein: 1h\n  unknown: 1\n  cacheMode: 2\n  store:\n    Unknown: 1\n")
+	if gToken, err = gtoken.NewFromConfig(ctx, "auth"); err != nil {
+		t.Fatal(err)
+	}
+	if string(gToken.SecretKey) != "case-secret" || gToken.ExpireIn != time.Hour || gToken.CacheMode != gtoken.CacheModeFile {
+		t.Error("error: GToken is not correct:", gToken)
+	}
+	useConfig(t, "auth:\n  cacheMode: 2\n  store:\n    mode: redis\n")
+	if gToken, err = gtoken.NewFromConfig(ctx, "auth"); err != nil || gToken.CacheMode != gtoken.CacheModeRedis {
+		t.Error("error: store.mode should take priority over cacheMode:", err)
+	}
+}
@@ -0,0 +1,49 @@
+package gtoken
+
+import (
+	"context"
+)
+
+// tokenInfoCtxKey is unexported to avoid collisions with ctx vars set by DoAfterAuth
+type tokenInfoCtxKey struct{}
+
+// authenticatedByCtxKey marks the GToken whose authMiddleware authenticated the request
+type authenticatedByCtxKey struct{}
+
+// NewContext returns a copy of ctx which carries the given token info.
+// authMiddleware calls it for every authenticated request.
+func NewContext(ctx context.Context, tokenInfo *TokenInfo) context.Context {
+	return context.WithValue(ctx, tokenInfoCtxKey{}, tokenInfo)
+}
+
+// FromContext returns the token info of the authenticated session.
+// ok is false for anonymous requests, e.g. public paths or optional paths without token.
+func FromContext(ctx context.Context) (tokenInfo *TokenInfo, ok bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	tokenInfo, ok = ctx.Value(tokenInfoCtxKey{}).(*TokenInfo)
+	return tokenInfo, ok && tokenInfo != nil
+}
+
+// UserIDFromContext returns the user id of the authenticated session, or "" for anonymous requests
+func UserIDFromContext(ctx context.Context) string {
+	if tokenInfo, ok := FromContext(ctx); ok {
+		return tokenInfo.UserID
+	}
+	return ""
+}
+
+// TokenIDFromContext returns the token id of the authenticated session, or "" for anonymous requests
+func TokenIDFromContext(ctx context.Context) string {
+	if tokenInfo, ok := FromContext(ctx); ok {
+		return tokenInfo.TokenID
+	}
+	return ""
+}
+
+// authenticatedBy returns the GToken whose authMiddleware authenticated the request, or nil
+func authenticatedBy(ctx context.Context) *GToken {
+	m, _ := ctx.Value(authenticatedByCtxKey{}).(*GToken)
+	return m
+}
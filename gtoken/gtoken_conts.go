@@ -13,34 +13,171 @@ const (
 	DefaultExpireIn      = 7 * 24 * time.Hour
 	DefaultSecretKey     = "g1t@o3K!e7n"
 	DefaultTokenIDLength = 12
+	DefaultAlgorithm     = "HS256"
 
 	TokenKeyInRequest = "token" // ok for: router, query, body, form, custom
 
 	DefaultLogPrefix = "[GToken]"
 
-	DefaultPrefixToken = "jwt:"
-	DefaultPrefixUser  = "user:"
+	DefaultPrefixToken   = "jwt:"
+	DefaultPrefixUser    = "user:"
+	DefaultPrefixTenant  = "tenant:"
+	DefaultPrefixWatcher = "gtoken:" // name prefix of config watchers added by WatchConfig
 
 	PrefixBearer = "Bearer "
 
-	DefaultCodeOK           = 0
-	DefaultCodeUnauthorized = 401
+	GRPCMetadataAuthorization = "authorization"
+
+	WebSocketProtocolBearer          = "bearer" // Sec-WebSocket-Protocol: bearer, {token}
+	WebSocketCloseUnauthorized       = 4401     // close code when the handshake fails or the token is revoked, like http 401
+	WebSocketCloseForbidden          = 4403     // close code when the token of a handshake lacks scopes or is denied by Policy, like http 403
+	DefaultWebSocketHandshakeTimeout = 10 * time.Second
+
+	SSEEventReauth             = "reauth" // event written by WriteSSEReauth when a stream ends by its token
+	DefaultStreamCheckInterval = 30 * time.Second
+
+	DefaultSweepInterval = 10 * time.Minute // interval of StartSweeper
+
+	DefaultAdminStatsCacheTTL = 10 * time.Second // how long /stats of MountAdmin caches its count
+
+	MetaTagAuth   = "auth"   // e.g. g.Meta `path:"/user" method:"get" auth:"false"`
+	MetaTagScopes = "scopes" // e.g. g.Meta `path:"/user" method:"get" scopes:"user:read"`
+
+	AuthModeRequired = "true"
+	AuthModePublic   = "false"
+	AuthModeOptional = "optional" // validate a token if present, or go on anonymously
+
+	PrefixRegexPattern = "~" // e.g. "GET:~/v[0-9]+/ping", matched against the whole path
+	MethodAll          = "ALL"
+
+	DefaultCodeOK            = 0
+	DefaultCodeUnauthorized  = 401
+	DefaultCodeForbidden     = 403
+	DefaultCodeBadRequest    = 400
+	DefaultCodeNotFound      = 404
+	DefaultCodeInternalError = 500
+
+	ReasonPermissionDenied  = "permission_denied"
+	ReasonInsufficientScope = "insufficient_scope"
+	ReasonTokenRevoked      = "token_revoked"
+	ReasonSessionReplaced   = "session_replaced"
+	ReasonTokenExpired      = "token_expired"
+	ReasonAdminRevoked      = "admin_revoked"
+	ReasonCrossTenant       = "cross_tenant"
+
+	OAuthErrorInvalidRequest = "invalid_request" // error codes of RFC 6749, used by IntrospectionHandler and RevocationHandler
+	OAuthErrorInvalidClient  = "invalid_client"
+	OAuthErrorUnavailable    = "temporarily_unavailable"
+	OAuthTokenTypeBearer     = "Bearer"
+
+	PolicyOpEqual    = "eq" // claim equals the path param
+	PolicyOpContains = "in" // claim is a list containing the path param
+
+	ClaimUserID  = "userID"
+	ClaimTokenID = "tokenID"
+
+	AdminParamTenant = "tenant" // query param of the admin API selecting a tenant, also the param of WithCrossTenantPolicy
+
+	EventIssue            = "issue"
+	EventValidate         = "validate"
+	EventRefresh          = "refresh"
+	EventRevoke           = "revoke"
+	DefaultEventQueueSize = 1024
+
+	AuditTokenIssued      = "token_issued"
+	AuditValidationFailed = "validation_failed"
+	AuditTokenRevoked     = "token_revoked"
+	AuditTokenRefreshed   = "token_refreshed"
+	RedactedValue         = "***"
+
+	LogFieldError = "error" // key of the error field in logs
+
+	InstrumentationName  = "github.com/mayugene/gtoken"
+	MetricTokensIssued   = "gtoken.tokens.issued"
+	MetricValidations    = "gtoken.validations"
+	MetricRefreshes      = "gtoken.refreshes"
+	MetricRevocations    = "gtoken.revocations"
+	MetricStoreDuration  = "gtoken.store.duration"
+	MetricSessionsActive = "gtoken.sessions.active"
+	MetricAttrTenant     = "tenant"
+	MetricAttrOutcome    = "outcome"
+	MetricAttrReason     = "reason"
+	MetricAttrBackend    = "backend"
+	MetricAttrOperation  = "operation"
+	MetricOutcomeSuccess = "success"
+	MetricOutcomeFailure = "failure"
+
+	SpanAuthMiddleware     = "gtoken.authMiddleware"
+	SpanNewToken           = "gtoken.NewToken"
+	SpanValidateToken      = "gtoken.ValidateToken"
+	SpanIntrospectToken    = "gtoken.IntrospectToken"
+	SpanStorePrefix        = "gtoken.store."
+	TraceAttrCacheMode     = "cache_mode"
+	TraceAttrTokenIDHash   = "token_id_hash" // first 16 hex chars of sha256 of the token id, never the raw token
+	TraceAttrAuthMode      = "auth_mode"
+	TraceAttrRoute         = "route"
+	TraceAttrTenant        = "tenant"
+	TraceAttrOutcome       = "outcome"
+	TraceAttrOperation     = "operation"
+	TraceOutcomePublic     = "public"
+	TraceOutcomeSkipped    = "skipped"
+	TraceOutcomeAnonymous  = "anonymous"
+	TraceOutcomeAuthorized = "authorized"
+	TraceOutcomeRejected   = "rejected"
+
+	StoreOpSet        = "set"
+	StoreOpGet        = "get"
+	StoreOpRefresh    = "refresh"
+	StoreOpRemove     = "remove"
+	StoreOpRemoveUser = "remove_user"
+	StoreOpCompact    = "compact"
+	StoreOpGetUser    = "get_user"
 )
 
+// DefaultAuditRedactKeys are redacted from params of audit events and auth failure logs, matched case-insensitively as substrings
+var DefaultAuditRedactKeys = []string{"password", "passwd", "pwd", "secret", "token", "authorization", "credential", "api_key", "apikey"}
+
 const (
-	errorReqMethod     = "request method is error! "
-	errorTokenEmpty    = "token is empty"
-	errorTokenEncrypt  = "token encrypt error"
-	errorTokenDecode   = "token decode error"
-	errorSetCache      = "set cache error"
-	errorGetCache      = "get cache error"
-	errorDeleteCache   = "delete cache error"
-	errorDecodeCache   = "decode cache error"
-	errorEncodeJson    = "encode json error"
-	errorUseCache      = "cache error"
-	errorInvalidMode   = "invalid mode"
-	errorWriteFile     = "write file error"
-	errorTokenNotFound = "token not found"
-	errorUnauthorized  = "unauthorized"
-	errorUseRedis      = "use redis error"
+	errorReqMethod            = "request method is error! "
+	errorTokenEmpty           = "token is empty"
+	errorTokenEncrypt         = "token encrypt error"
+	errorTokenDecode          = "token decode error"
+	errorSetCache             = "set cache error"
+	errorGetCache             = "get cache error"
+	errorDeleteCache          = "delete cache error"
+	errorDecodeCache          = "decode cache error"
+	errorEncodeJson           = "encode json error"
+	errorUseCache             = "cache error"
+	errorInvalidMode          = "invalid mode"
+	errorWriteFile            = "write file error"
+	errorTokenNotFound        = "token not found"
+	errorUnauthorized         = "unauthorized"
+	errorUseRedis             = "use redis error"
+	errorInvalidPattern       = "invalid path pattern"
+	errorInvalidMetaTag       = "invalid auth meta tag"
+	errorForbidden            = "forbidden"
+	errorRoleCycle            = "role inheritance cycle"
+	errorRBACNotSet           = "RBAC is not set, all permissions are denied"
+	errorInvalidPolicyOp      = "invalid policy op"
+	errorInvalidTenant        = "invalid tenant id"
+	errorTenantNotFound       = "tenant not found"
+	errorTenantMismatch       = "token belongs to another tenant"
+	errorTenantResolverNotSet = "TenantResolver is required when Tenants is set"
+	errorEventQueueFull       = "event queue is full, event dropped"
+	errorEventListener        = "event listener panic"
+	errorWriteAudit           = "write audit log error"
+	errorInvalidAlgorithm     = "invalid algorithm, only HS256, HS384 and HS512 are supported"
+	errorInvalidConfig        = "invalid config"
+	errorConfigNotFound       = "config section not found"
+	warnUnknownConfigKey      = "unknown config key is ignored"
+	warnDeprecatedConfigKey   = "deprecated config key"
+	errorNotInitialized       = "GToken is not initialized, call Init first"
+	errorReloadTenantView     = "a tenant view cannot be reloaded, reload its GToken instead"
+	errorReloadFixed          = "setting cannot be reloaded"
+	errorConfigNotWatchable   = "config adapter does not support watching"
+	errorReloadConfig         = "reload config error"
+	errorCompact              = "compact user index error"
+	errorRevokeToken          = "revoke token error"
+	errorAdminPolicyNotSet    = "a Policy is required to protect the admin API"
+	errorInvalidSession       = "session requires a user id and a token id"
 )
@@ -0,0 +1,88 @@
+package gtoken
+
+import (
+	"context"
+)
+
+// TokenEvent is passed to listeners of token lifecycle events
+type TokenEvent struct {
+	Type      string     // EventIssue, EventValidate, EventRefresh or EventRevoke
+	TokenInfo *TokenInfo // the token the event is about
+	Reason    string     // why a token is revoked, e.g. ReasonTokenRevoked or ReasonSessionReplaced. Empty for other events
+}
+
+// TokenListener is called for a token lifecycle event, e.g. to update last-seen timestamps or notify users of new logins.
+// The ctx of async listeners is not canceled with the request, but keeps its values.
+type TokenListener func(ctx context.Context, event TokenEvent)
+
+// eventTask is a queued call of listeners
+type eventTask struct {
+	ctx       context.Context
+	listeners []TokenListener
+	event     TokenEvent
+}
+
+// eventQueue calls listeners in a background goroutine. It is shared by tenant views.
+type eventQueue struct {
+	tasks chan eventTask
+	log   LoggerFunc
+}
+
+func newEventQueue(size int, log LoggerFunc) *eventQueue {
+	q := &eventQueue{tasks: make(chan eventTask, size), log: log}
+	go q.run()
+	return q
+}
+
+func (q *eventQueue) run() {
+	for task := range q.tasks {
+		for _, listener := range task.listeners {
+			q.call(task.ctx, listener, task.event)
+		}
+	}
+}
+
+// push never blocks the caller, so an event is dropped if the queue is full
+func (q *eventQueue) push(ctx context.Context, listeners []TokenListener, event TokenEvent) {
+	select {
+	case q.tasks <- eventTask{ctx: context.WithoutCancel(ctx), listeners: listeners, event: event}:
+	default:
+		q.log(ctx, LogLevelWarning, errorEventQueueFull, "event", event.Type)
+	}
+}
+
+// call keeps the queue running if a listener panics
+func (q *eventQueue) call(ctx context.Context, listener TokenListener, event TokenEvent) {
+	defer func() {
+		if e := recover(); e != nil {
+			q.log(ctx, LogLevelError, errorEventListener, "event", event.Type, "panic", e)
+		}
+	}()
+	listener(ctx, event)
+}
+
+// emit writes the audit event, and calls listeners synchronously, or pushes them to the queue if AsyncEvents is true
+func (m *GToken) emit(ctx context.Context, listeners []TokenListener, event TokenEvent) {
+	m.auditToken(ctx, event)
+	if len(listeners) == 0 {
+		return
+	}
+	if m.events != nil {
+		m.events.push(ctx, listeners, event)
+		return
+	}
+	for _, listener := range listeners {
+		listener(ctx, event)
+	}
+}
+
+// revoked fires OnRevoke, records metrics and closes WebSocket connections of removed tokens
+func (m *GToken) revoked(ctx context.Context, reason string, tokenInfos ...*TokenInfo) {
+	tokenIDs := make([]string, 0, len(tokenInfos))
+	for _, tokenInfo := range tokenInfos {
+		tokenIDs = append(tokenIDs, tokenInfo.TokenID)
+		m.emit(ctx, m.OnRevoke, TokenEvent{Type: EventRevoke, TokenInfo: tokenInfo, Reason: reason})
+	}
+	m.recordRevocations(ctx, reason, len(tokenInfos))
+	m.closeWebSockets(reason, tokenIDs...)
+}
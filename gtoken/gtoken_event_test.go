@@ -0,0 +1,115 @@
+package gtoken_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+func TestEventListeners(t *testing.T) {
+	t.Log("test: token lifecycle events")
+	ctx := context.Background()
+	var events []gtoken.TokenEvent
+	record := func(ctx context.Context, event gtoken.TokenEvent) {
+		events = append(events, event)
+	}
+	gToken := &gtoken.GToken{
+		ExpireIn:         time.Second,
+		SingleSession:    true,
+		AutoRefreshToken: true,
+		OnIssue:          []gtoken.TokenListener{record},
+		OnValidate:       []gtoken.TokenListener{record},
+		OnRefresh:        []gtoken.TokenListener{record},
+		OnRevoke:         []gtoken.TokenListener{record},
+	}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	expect := func(types ...string) {
+		if len(events) != len(types) {
+			t.Error("error: events should be", types, "but:", events)
+		} else {
+			for i, eventType := range types {
+				if events[i].Type != eventType || events[i].TokenInfo.UserID != userId {
+					t.Error("error: event should be", eventType, "but:", events[i])
+				}
+			}
+		}
+		events = nil
+	}
+
+	t.Log("1. NewToken fires OnIssue")
+	token, _, err := gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(gtoken.EventIssue)
+
+	t.Log("2. ValidateToken fires OnValidate, and OnRefresh after RefreshAt")
+	if _, err = gToken.ValidateToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	expect(gtoken.EventValidate)
+	time.Sleep(600 * time.Millisecond)
+	if _, err = gToken.ValidateToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	expect(gtoken.EventRefresh, gtoken.EventValidate)
+
+	t.Log("3. SingleSession eviction fires OnRevoke")
+	newToken, _, err := gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 2 && events[0].Reason != gtoken.ReasonSessionReplaced {
+		t.Error("error: reason should be session_replaced, but:", events[0].Reason)
+	}
+	expect(gtoken.EventRevoke, gtoken.EventIssue)
+
+	t.Log("4. RemoveToken fires OnRevoke")
+	if _, err = gToken.RemoveToken(ctx, newToken); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 1 && events[0].Reason != gtoken.ReasonTokenRevoked {
+		t.Error("error: reason should be token_revoked, but:", events[0].Reason)
+	}
+	expect(gtoken.EventRevoke)
+}
+
+func TestAsyncEvents(t *testing.T) {
+	t.Log("test: async token lifecycle events")
+	ctx, cancel := context.WithCancel(context.Background())
+	issued := make(chan gtoken.TokenEvent, 1)
+	gToken := &gtoken.GToken{
+		AsyncEvents: true,
+		OnIssue: []gtoken.TokenListener{
+			func(ctx context.Context, event gtoken.TokenEvent) {
+				panic("a broken listener does not stop the queue")
+			},
+			func(ctx context.Context, event gtoken.TokenEvent) {
+				if ctx.Err() != nil {
+					t.Error("error: ctx of async listeners should not be canceled")
+				}
+				issued <- event
+			},
+		},
+	}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	_, tokenInfo, err := gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case event := <-issued:
+		if event.TokenInfo.TokenID != tokenInfo.TokenID {
+			t.Error("error: event is not correct:", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("error: async listener is not called")
+	}
+}
@@ -0,0 +1,125 @@
+package gtoken
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor validates the bearer token in the "authorization" metadata of unary calls,
+// and puts TokenInfo in the context, so FromContext works in handlers.
+// Methods in PublicMethods skip auth. Init must be called before, or all methods are protected.
+//
+// A gRPC call is seen as "POST {fullMethod}" with metadata as headers,
+// so TenantResolver, ScopeRules and Policy work the same as http, e.g. ScopeRules{"/orders.Orders/Create": {"orders:write"}}.
+func (m *GToken) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := m.authGRPC(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls
+func (m *GToken) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := m.authGRPC(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authedServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// UnaryClientInterceptor attaches the token to the "authorization" metadata of unary calls
+func UnaryClientInterceptor(token string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withGRPCToken(ctx, token), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor attaches the token to the "authorization" metadata of streaming calls
+func StreamClientInterceptor(token string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withGRPCToken(ctx, token), desc, cc, method, opts...)
+	}
+}
+
+// authedServerStream replaces the context of a stream with the authenticated one
+type authedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func withGRPCToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, GRPCMetadataAuthorization, PrefixBearer+token)
+}
+
+func (m *GToken) authGRPC(ctx context.Context, fullMethod string) (context.Context, error) {
+	if m.settings().publicMethodMatcher.matches(fullMethod, http.MethodPost) {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	r := grpcRequest(ctx, md, fullMethod)
+	token := parseAuthorizationHeader(r.Header.Get(GRPCMetadataAuthorization))
+	gt, err := m.ForRequest(r)
+	if err != nil {
+		m.auditValidationFailed(r, err.Error())
+		return nil, status.Error(codes.Unauthenticated, errorUnauthorized)
+	}
+	if token == "" {
+		gt.auditValidationFailed(r, errorTokenEmpty)
+		return nil, status.Error(codes.Unauthenticated, errorUnauthorized)
+	}
+	tokenInfo, err := gt.ValidateToken(ctx, token)
+	if err != nil {
+		gt.auditValidationFailed(r, err.Error())
+		return nil, status.Error(codes.Unauthenticated, errorUnauthorized)
+	}
+	if required := gt.ruleScopes(fullMethod, http.MethodPost); !tokenInfo.HasScopes(required...) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s: %s", ReasonInsufficientScope, strings.Join(required, " "))
+	}
+	if gt.Policy != nil {
+		if allow, reason := gt.Policy.Evaluate(r, nil, tokenInfo); !allow {
+			return nil, status.Error(codes.PermissionDenied, reason)
+		}
+	}
+	return NewContext(ctx, tokenInfo), nil
+}
+
+// grpcRequest builds "POST {fullMethod}" with metadata as headers
+func grpcRequest(ctx context.Context, md metadata.MD, fullMethod string) *http.Request {
+	r := &http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: fullMethod},
+		Header: http.Header{},
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	for k, values := range md {
+		if k == ":authority" {
+			if len(values) > 0 {
+				r.Host = values[0]
+			}
+			continue
+		}
+		for _, v := range values {
+			r.Header.Add(k, v)
+		}
+	}
+	return r.WithContext(ctx)
+}
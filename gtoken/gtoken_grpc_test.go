@@ -0,0 +1,121 @@
+package gtoken_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/mayugene/gtoken/gtoken"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// userHealthServer answers with the user id in context as the service name is not important here
+type userHealthServer struct {
+	*health.Server
+	userIDs chan string
+}
+
+func (s *userHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.userIDs <- gtoken.UserIDFromContext(ctx)
+	return s.Server.Check(ctx, req)
+}
+
+func (s *userHealthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	s.userIDs <- gtoken.UserIDFromContext(stream.Context())
+	return s.Server.Watch(req, stream)
+}
+
+func TestGRPCInterceptors(t *testing.T) {
+	t.Log("test: gRPC interceptors")
+	ctx := context.Background()
+	gToken := &gtoken.GToken{
+		PublicMethods: []string{"/grpc.health.v1.Health/List"},
+		ScopeRules:    map[string][]string{"/grpc.health.v1.Health/Watch": {"health:watch"}},
+	}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	// a token without scopes is not restricted, so it has another scope to be denied by ScopeRules
+	token, _, err := gToken.NewToken(ctx, userId, nil, gtoken.WithScopes("health:check"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(gToken.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(gToken.StreamServerInterceptor()),
+	)
+	healthServer := &userHealthServer{Server: health.NewServer(), userIDs: make(chan string, 1)}
+	healthpb.RegisterHealthServer(server, healthServer)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	dial := func(opts ...grpc.DialOption) healthpb.HealthClient {
+		opts = append(opts,
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+				return listener.Dial()
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		conn, err1 := grpc.NewClient("passthrough:///bufnet", opts...)
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		t.Cleanup(func() {
+			_ = conn.Close()
+		})
+		return healthpb.NewHealthClient(conn)
+	}
+	anonymous := dial()
+	authed := dial(
+		grpc.WithUnaryInterceptor(gtoken.UnaryClientInterceptor(token)),
+		grpc.WithStreamInterceptor(gtoken.StreamClientInterceptor(token)),
+	)
+
+	t.Log("1. public method goes on without token")
+	if _, err = anonymous.List(ctx, &healthpb.HealthListRequest{}); err != nil {
+		t.Error("error:", err)
+	}
+	t.Log("2. protected method without token is unauthenticated")
+	if _, err = anonymous.Check(ctx, &healthpb.HealthCheckRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Error("error: code should be Unauthenticated, but:", err)
+	}
+	t.Log("3. unary call with token puts TokenInfo in context")
+	if _, err = authed.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Error("error:", err)
+	} else if id := <-healthServer.userIDs; id != userId {
+		t.Error("error: user id in context is not correct:", id)
+	}
+	t.Log("4. stream call lacking scopes is denied")
+	stream, err := authed.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Error("error: code should be PermissionDenied, but:", err)
+	}
+	t.Log("5. stream call with scopes puts TokenInfo in context")
+	watchToken, _, err := gToken.NewToken(ctx, userId, nil, gtoken.WithScopes("health:watch"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher := dial(grpc.WithStreamInterceptor(gtoken.StreamClientInterceptor(watchToken)))
+	stream, err = watcher.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if err != nil {
+		t.Error("error:", err)
+	} else if id := <-healthServer.userIDs; id != userId {
+		t.Error("error: user id in context is not correct:", id)
+	}
+}
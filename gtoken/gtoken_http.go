@@ -0,0 +1,91 @@
+package gtoken
+
+import (
+	"net/http"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// HTTPMiddleware adapts authMiddleware to plain http.Handler, e.g. pprof, a reverse proxy or third-party handlers.
+// It shares token parsing, PublicPaths, OptionalPaths, ScopeRules, Policy and tenants with authMiddleware,
+// and puts TokenInfo in the request context, so FromContext works in next.
+// g.Meta tags, DoBeforeAuth, DoAfterAuth and DoForbidden only exist for GoFrame, so they are not used here.
+// There are no handler params either, so Policy gets nil params, and a RulePolicy only gets the params captured by its own rules.
+// Init must be called before, or all paths are protected.
+//
+//	mux.Handle("/debug/pprof/", gToken.HTTPMiddleware(http.DefaultServeMux))
+func (m *GToken) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := m.settings()
+		mode := s.pathMode(r.URL.Path, r.Method)
+		if mode == AuthModePublic {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := ParseHTTPRequestToken(r)
+		// anonymous requests are allowed on optional paths
+		if token == "" && mode == AuthModeOptional {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gt, err := m.ForRequest(r)
+		if err != nil {
+			m.auditValidationFailed(r, err.Error())
+			writeHTTPUnauthorized(w)
+			return
+		}
+		if token == "" {
+			gt.auditValidationFailed(r, errorTokenEmpty)
+			writeHTTPUnauthorized(w)
+			return
+		}
+		tokenInfo, err := gt.ValidateToken(r.Context(), token)
+		if err != nil {
+			gt.auditValidationFailed(r, err.Error())
+			if mode == AuthModeOptional && !s.config.RejectInvalid {
+				next.ServeHTTP(w, r)
+				return
+			}
+			writeHTTPUnauthorized(w)
+			return
+		}
+
+		if required := gt.ruleScopes(r.URL.Path, r.Method); !tokenInfo.HasScopes(required...) {
+			w.Header().Set("WWW-Authenticate", insufficientScopeChallenge(required))
+			writeHTTPForbidden(w, g.Map{"reason": ReasonInsufficientScope, "required": required})
+			return
+		}
+		if gt.Policy != nil {
+			if allow, reason := gt.Policy.Evaluate(r, nil, tokenInfo); !allow {
+				writeHTTPForbidden(w, g.Map{"reason": reason})
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), tokenInfo)))
+	})
+}
+
+// writeHTTPUnauthorized writes the same body as the default DoAfterAuth, with the real http status
+func writeHTTPUnauthorized(w http.ResponseWriter) {
+	writeHTTPJson(w, http.StatusUnauthorized, DefaultResponse{
+		Code: DefaultCodeUnauthorized,
+		Msg:  errorUnauthorized,
+	})
+}
+
+// writeHTTPForbidden writes the same body as the default DoForbidden, with the real http status
+func writeHTTPForbidden(w http.ResponseWriter, data g.Map) {
+	writeHTTPJson(w, http.StatusForbidden, DefaultResponse{
+		Code: DefaultCodeForbidden,
+		Msg:  errorForbidden,
+		Data: data,
+	})
+}
+
+func writeHTTPJson(w http.ResponseWriter, status int, res DefaultResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(gjson.MustEncode(res))
+}
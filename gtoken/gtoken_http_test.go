@@ -0,0 +1,112 @@
+package gtoken_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	t.Log("test: net/http middleware")
+	ctx := context.Background()
+	gToken := &gtoken.GToken{
+		PublicPaths:   []string{"/public"},
+		OptionalPaths: []string{"/optional"},
+		ScopeRules:    map[string][]string{"/admin/*": {"admin"}},
+	}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	handler := gToken.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("user:" + gtoken.UserIDFromContext(r.Context())))
+	}))
+	token, _, err := gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(path string, authorization string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Log("1. public path goes on without token")
+	if w := serve("/public", ""); w.Code != http.StatusOK || w.Body.String() != "user:" {
+		t.Error("error:", w.Code, w.Body.String())
+	}
+	t.Log("2. protected path without token gets 401")
+	w := serve("/user", "")
+	if w.Code != http.StatusUnauthorized {
+		t.Error("error:", w.Code, w.Body.String())
+	}
+	if res := (gtoken.DefaultResponse{}); gjson.DecodeTo(w.Body.Bytes(), &res) != nil || res.Code != gtoken.DefaultCodeUnauthorized {
+		t.Error("error: body is not a DefaultResponse:", w.Body.String())
+	}
+	t.Log("3. token in header puts TokenInfo in context")
+	if w = serve("/user", gtoken.PrefixBearer+token); w.Code != http.StatusOK || w.Body.String() != "user:"+userId {
+		t.Error("error:", w.Code, w.Body.String())
+	}
+	t.Log("4. token in query is accepted")
+	if w = serve("/user?token="+token, ""); w.Code != http.StatusOK {
+		t.Error("error:", w.Code, w.Body.String())
+	}
+	t.Log("5. optional path treats an invalid token as anonymous")
+	if w = serve("/optional", gtoken.PrefixBearer+"invalid"); w.Code != http.StatusOK || w.Body.String() != "user:" {
+		t.Error("error:", w.Code, w.Body.String())
+	}
+	t.Log("6. lacking scopes gets 403, a token without scopes is not restricted")
+	scopedToken, _, err := gToken.NewToken(ctx, userId, nil, gtoken.WithScopes("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w = serve("/admin/users", gtoken.PrefixBearer+scopedToken); w.Code != http.StatusForbidden || w.Header().Get("WWW-Authenticate") == "" {
+		t.Error("error:", w.Code, w.Body.String())
+	}
+	if w = serve("/admin/users", gtoken.PrefixBearer+token); w.Code != http.StatusOK {
+		t.Error("error:", w.Code, w.Body.String())
+	}
+	t.Log("7. Sec-WebSocket-Protocol is only read from a WebSocket upgrade")
+	for upgrade, code := range map[string]int{"": http.StatusUnauthorized, "websocket": http.StatusOK} {
+		r := httptest.NewRequest(http.MethodGet, "/user", nil)
+		r.Header.Set("Sec-WebSocket-Protocol", gtoken.WebSocketProtocolBearer+", "+token)
+		if upgrade != "" {
+			r.Header.Set("Connection", "Upgrade")
+			r.Header.Set("Upgrade", upgrade)
+		}
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Error("error: upgrade", upgrade, "should be", code, "but:", w.Code)
+		}
+	}
+	t.Log("8. body is never read for a token")
+	body := "token=" + token
+	r := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Authorization", gtoken.PrefixBearer+token)
+	w = httptest.NewRecorder()
+	gToken.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	})).ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != body {
+		t.Error("error: body should be left for next, but:", w.Code, w.Body.String())
+	}
+	r = httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Error("error: token in body should not be accepted, but:", w.Code, w.Body.String())
+	}
+}
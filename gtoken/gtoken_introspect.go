@@ -0,0 +1,128 @@
+package gtoken
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// ClientCredentials maps client ids to client secrets of services which may call IntrospectionHandler and RevocationHandler
+type ClientCredentials map[string]string
+
+// IntrospectionResponse is the body of IntrospectionHandler, see RFC 7662 section 2.2.
+// ExtraData of a token is returned as "ext", so it never shadows the standard members.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Sub       string `json:"sub,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Ext       g.Map  `json:"ext,omitempty"`
+}
+
+// IntrospectionHandler returns an OAuth 2.0 Token Introspection endpoint (RFC 7662), so other services can check
+// tokens without the secret. A client posts "token" as a form, authenticated by http basic auth or the
+// "client_id" and "client_secret" form values of clients. An invalid, expired or revoked token is {"active": false}.
+// Tokens are validated like ValidateToken, but never refreshed by AutoRefreshToken. Tenants are resolved by TenantResolver.
+//
+//	s.BindHandler("POST:/oauth/introspect", ghttp.WrapH(gToken.IntrospectionHandler(gtoken.ClientCredentials{
+//	    "orders-service": "a-long-random-secret",
+//	})))
+func (m *GToken) IntrospectionHandler(clients ClientCredentials) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeOAuthError(w, http.StatusMethodNotAllowed, OAuthErrorInvalidRequest)
+			return
+		}
+		if _, ok := clients.authenticate(r); !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="gtoken"`)
+			writeOAuthError(w, http.StatusUnauthorized, OAuthErrorInvalidClient)
+			return
+		}
+		token := r.PostFormValue("token")
+		if token == "" {
+			writeOAuthError(w, http.StatusBadRequest, OAuthErrorInvalidRequest)
+			return
+		}
+		writeOAuthJson(w, http.StatusOK, m.introspect(r, token))
+	})
+}
+
+// introspect validates a token without refreshing it, and returns its claims
+func (m *GToken) introspect(r *http.Request, token string) IntrospectionResponse {
+	gt, err := m.ForRequest(r)
+	if err != nil {
+		m.auditValidationFailed(r, err.Error())
+		return IntrospectionResponse{}
+	}
+	ctx, span := gt.startSpan(r.Context(), SpanIntrospectToken)
+	tokenInfo, err := gt.validateToken(ctx, token, false)
+	gt.recordValidation(ctx, err)
+	endTokenSpan(span, tokenInfo, err)
+	if err != nil {
+		gt.auditValidationFailed(r, err.Error())
+		return IntrospectionResponse{}
+	}
+	res := IntrospectionResponse{
+		Active:    true,
+		Sub:       tokenInfo.UserID,
+		Scope:     strings.Join(tokenInfo.Scopes, " "),
+		ClientID:  tokenInfo.ClientID,
+		Jti:       tokenInfo.TokenID,
+		TokenType: OAuthTokenTypeBearer,
+		Ext:       tokenInfo.ExtraData,
+	}
+	if tokenInfo.ExpireAt != nil {
+		res.Exp = tokenInfo.ExpireAt.Unix()
+	}
+	if tokenInfo.IssuedAt != nil {
+		res.Iat = tokenInfo.IssuedAt.Unix()
+	}
+	return res
+}
+
+// authenticate checks the client by http basic auth, or the form values, and returns its id, see RFC 6749 section 2.3.1.
+// Without clients, every request is rejected.
+func (c ClientCredentials) authenticate(r *http.Request) (string, bool) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		// the id and secret are form-urlencoded before basic auth encodes them
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err != nil {
+			return "", false
+		}
+		if clientSecret, err = url.QueryUnescape(clientSecret); err != nil {
+			return "", false
+		}
+	} else {
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	secret, found := c[clientID]
+	// compare in constant time even for an unknown client, so ids cannot be probed by timing
+	match := subtle.ConstantTimeCompare([]byte(secret), []byte(clientSecret)) == 1
+	if !found || secret == "" || !match {
+		return "", false
+	}
+	return clientID, true
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code string) {
+	writeOAuthJson(w, status, g.Map{"error": code})
+}
+
+// writeOAuthJson writes a json body which must not be cached, see RFC 6749 section 5.1
+func writeOAuthJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	_, _ = w.Write(gjson.MustEncode(body))
+}
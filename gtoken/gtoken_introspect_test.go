@@ -0,0 +1,88 @@
+package gtoken_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+func TestIntrospectionHandler(t *testing.T) {
+	t.Log("test: token introspection endpoint")
+	ctx := context.Background()
+	gToken := &gtoken.GToken{ExpireIn: time.Second, AutoRefreshToken: true}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	handler := gToken.IntrospectionHandler(gtoken.ClientCredentials{"orders": "orders-secret"})
+	introspect := func(method string, form url.Values, basicAuth ...string) (*httptest.ResponseRecorder, *gjson.Json) {
+		r := httptest.NewRequest(method, "/oauth/introspect", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if len(basicAuth) == 2 {
+			r.SetBasicAuth(basicAuth[0], basicAuth[1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w, gjson.New(w.Body.String())
+	}
+	token, tokenInfo, err := gToken.NewToken(ctx, userId, g.Map{"name": "John Doe"},
+		gtoken.WithScopes("orders:read", "orders:write"), gtoken.WithClientID("web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("1. clients are authenticated")
+	if w, res := introspect(http.MethodPost, url.Values{"token": {token}}); w.Code != http.StatusUnauthorized ||
+		res.Get("error").String() != gtoken.OAuthErrorInvalidClient || w.Header().Get("WWW-Authenticate") == "" {
+		t.Error("error: request without credentials should be rejected:", w.Code, w.Body.String())
+	}
+	if w, _ := introspect(http.MethodPost, url.Values{"token": {token}}, "orders", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Error("error: wrong secret should be rejected:", w.Code)
+	}
+	if w, _ := introspect(http.MethodGet, url.Values{"token": {token}}, "orders", "orders-secret"); w.Code != http.StatusMethodNotAllowed {
+		t.Error("error: GET should not be allowed:", w.Code)
+	}
+	if w, res := introspect(http.MethodPost, url.Values{}, "orders", "orders-secret"); w.Code != http.StatusBadRequest ||
+		res.Get("error").String() != gtoken.OAuthErrorInvalidRequest {
+		t.Error("error: request without token should be rejected:", w.Code, w.Body.String())
+	}
+
+	t.Log("2. an active token returns its claims")
+	w, res := introspect(http.MethodPost, url.Values{"token": {token}}, "orders", "orders-secret")
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatal("error:", w.Code, w.Body.String())
+	}
+	if !res.Get("active").Bool() || res.Get("sub").String() != userId || res.Get("jti").String() != tokenInfo.TokenID ||
+		res.Get("scope").String() != "orders:read orders:write" || res.Get("client_id").String() != "web" ||
+		res.Get("exp").Int64() != tokenInfo.ExpireAt.Unix() || res.Get("iat").Int64() != tokenInfo.IssuedAt.Unix() ||
+		res.Get("ext.name").String() != "John Doe" {
+		t.Error("error: claims are not correct:", w.Body.String())
+	}
+
+	t.Log("3. client credentials in the form are accepted, and the token is not refreshed")
+	time.Sleep(600 * time.Millisecond)
+	form := url.Values{"token": {token}, "client_id": {"orders"}, "client_secret": {"orders-secret"}}
+	if w, res = introspect(http.MethodPost, form); !res.Get("active").Bool() {
+		t.Fatal("error: token should be active:", w.Code, w.Body.String())
+	}
+	if res.Get("exp").Int64() != tokenInfo.ExpireAt.Unix() {
+		t.Error("error: introspection should not refresh the token:", w.Body.String())
+	}
+	time.Sleep(500 * time.Millisecond)
+	if _, res = introspect(http.MethodPost, form); res.Get("active").Bool() {
+		t.Error("error: token should expire at its original time:", res.String())
+	}
+
+	t.Log("4. an invalid token is inactive without other members")
+	if w, _ = introspect(http.MethodPost, url.Values{"token": {"invalid"}}, "orders", "orders-secret"); w.Code != http.StatusOK ||
+		w.Body.String() != `{"active":false}` {
+		t.Error("error: invalid token should be inactive:", w.Code, w.Body.String())
+	}
+}
@@ -3,8 +3,11 @@ package gtoken
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"strings"
 
 	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/glog"
 )
 
 const (
@@ -14,18 +17,104 @@ const (
 	LogLevelError   = "error"
 )
 
-func WriteLog(ctx context.Context, msg string, logLevel string) {
-	text := fmt.Sprintf("%s%s", DefaultLogPrefix, msg)
-	switch logLevel {
+// Logger receives logs of gtoken with key/value fields, e.g. Log(ctx, LogLevelError, "set cache error", "error", err)
+type Logger interface {
+	Log(ctx context.Context, level string, msg string, keysAndValues ...interface{})
+}
+
+// LoggerFunc is an adapter to use a func as Logger
+type LoggerFunc func(ctx context.Context, level string, msg string, keysAndValues ...interface{})
+
+func (f LoggerFunc) Log(ctx context.Context, level string, msg string, keysAndValues ...interface{}) {
+	f(ctx, level, msg, keysAndValues...)
+}
+
+// NopLogger drops all logs, e.g. to silence gtoken in tests
+var NopLogger Logger = LoggerFunc(func(ctx context.Context, level string, msg string, keysAndValues ...interface{}) {})
+
+// defaultLogger is used by WriteLog and when GToken.Logger is not set
+var defaultLogger = NewGLogLogger(nil)
+
+type glogLogger struct {
+	logger *glog.Logger
+}
+
+// NewGLogLogger adapts a GoFrame logger, or g.Log() if it is nil. Messages are prefixed with "[GToken]"
+// and fields are appended as "key=value".
+func NewGLogLogger(logger *glog.Logger) Logger {
+	return &glogLogger{logger: logger}
+}
+
+func (l *glogLogger) Log(ctx context.Context, level string, msg string, keysAndValues ...interface{}) {
+	logger := l.logger
+	if logger == nil {
+		logger = g.Log()
+	}
+	text := DefaultLogPrefix + msg + formatFields(keysAndValues)
+	switch level {
+	case LogLevelDebug:
+		logger.Debug(ctx, text)
+	case LogLevelWarning:
+		logger.Warning(ctx, text)
+	case LogLevelError:
+		logger.Error(ctx, text)
+	default:
+		logger.Info(ctx, text)
+	}
+}
+
+func formatFields(keysAndValues []interface{}) string {
+	var b strings.Builder
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 < len(keysAndValues) {
+			_, _ = fmt.Fprintf(&b, " %v=%v", keysAndValues[i], keysAndValues[i+1])
+		} else {
+			_, _ = fmt.Fprintf(&b, " %v", keysAndValues[i])
+		}
+	}
+	return b.String()
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger adapts a log/slog logger, or slog.Default() if it is nil. Fields are passed as slog attributes.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return &slogLogger{logger: logger}
+}
+
+func (l *slogLogger) Log(ctx context.Context, level string, msg string, keysAndValues ...interface{}) {
+	logger := l.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Log(ctx, slogLevel(level), msg, keysAndValues...)
+}
+
+func slogLevel(level string) slog.Level {
+	switch level {
 	case LogLevelDebug:
-		g.Log().Debug(ctx, text)
-	case LogLevelInfo:
-		g.Log().Info(ctx, text)
+		return slog.LevelDebug
 	case LogLevelWarning:
-		g.Log().Warning(ctx, text)
+		return slog.LevelWarn
 	case LogLevelError:
-		g.Log().Error(ctx, text)
+		return slog.LevelError
 	default:
-		g.Log().Info(ctx, text)
+		return slog.LevelInfo
+	}
+}
+
+// WriteLog writes by the default logger, which is g.Log()
+func WriteLog(ctx context.Context, msg string, logLevel string) {
+	defaultLogger.Log(ctx, logLevel, msg)
+}
+
+// log writes by GToken.Logger, or the default logger if it is not set
+func (m *GToken) log(ctx context.Context, level string, msg string, keysAndValues ...interface{}) {
+	if m.Logger != nil {
+		m.Logger.Log(ctx, level, msg, keysAndValues...)
+		return
 	}
+	defaultLogger.Log(ctx, level, msg, keysAndValues...)
 }
@@ -0,0 +1,83 @@
+package gtoken_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/os/glog"
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+func TestLoggerAdapters(t *testing.T) {
+	t.Log("test: logger adapters")
+	ctx := context.Background()
+
+	t.Log("1. slog adapter passes fields as attributes")
+	buf := &bytes.Buffer{}
+	slogLogger := gtoken.NewSlogLogger(slog.New(slog.NewJSONHandler(buf, nil)))
+	slogLogger.Log(ctx, gtoken.LogLevelError, "set cache error", gtoken.LogFieldError, errors.New("timeout"))
+	record := gjson.New(buf.Bytes())
+	if record.Get("level").String() != "ERROR" || record.Get("msg").String() != "set cache error" || record.Get("error").String() != "timeout" {
+		t.Error("error: slog record is not correct:", buf.String())
+	}
+
+	t.Log("2. glog adapter appends fields to the message")
+	buf.Reset()
+	logger := glog.New()
+	logger.SetWriter(buf)
+	logger.SetStdoutPrint(false)
+	gtoken.NewGLogLogger(logger).Log(ctx, gtoken.LogLevelWarning, "set cache error", gtoken.LogFieldError, "timeout")
+	if text := buf.String(); !strings.Contains(text, "WARN") || !strings.Contains(text, gtoken.DefaultLogPrefix+"set cache error error=timeout") {
+		t.Error("error: glog text is not correct:", text)
+	}
+}
+
+func TestGTokenLogger(t *testing.T) {
+	t.Log("test: GToken.Logger receives logs")
+	ctx := context.Background()
+	var mu sync.Mutex
+	var levels []string
+	logged := make(chan struct{}, 1)
+	gToken := &gtoken.GToken{
+		AsyncEvents: true,
+		Logger: gtoken.LoggerFunc(func(ctx context.Context, level string, msg string, keysAndValues ...interface{}) {
+			mu.Lock()
+			levels = append(levels, level)
+			mu.Unlock()
+			logged <- struct{}{}
+		}),
+		OnIssue: []gtoken.TokenListener{func(ctx context.Context, event gtoken.TokenEvent) {
+			panic("broken listener")
+		}},
+	}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	t.Log("1. panic of an async listener is logged by Logger")
+	if _, _, err := gToken.NewToken(ctx, userId, nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-logged:
+		mu.Lock()
+		if len(levels) != 1 || levels[0] != gtoken.LogLevelError {
+			t.Error("error: panic of listener should be logged as error, but:", levels)
+		}
+		mu.Unlock()
+	case <-time.After(2 * time.Second):
+		t.Error("error: Logger is not used")
+	}
+
+	t.Log("2. NopLogger drops logs")
+	silent := &gtoken.GToken{Logger: gtoken.NopLogger, PublicPaths: []string{"~("}}
+	if silent.Init(ctx) {
+		t.Error("error: init should fail with an invalid pattern")
+	}
+}
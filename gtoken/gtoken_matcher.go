@@ -0,0 +1,315 @@
+package gtoken
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// pathMatcher is a precompiled set of path rules, built once in Init.
+//
+// Rule format: "[METHODS:]PATTERN"
+//     METHODS is optional and comma separated, e.g. "GET", "post", "GET,HEAD". "ALL" matches any method.
+//     PATTERN is either a path pattern starting with "/" or a regex starting with "~".
+//
+// Supported path segments:
+//     /login          literal segment
+//     /user/{id}      one non-empty segment, GoFrame style
+//     /user/:id       one non-empty segment, GoFrame style
+//     /user/*/detail  one non-empty segment
+//     /docs/**/raw    zero or more segments
+//     /docs/**        zero or more segments, so /docs, /docs/ and /docs/a/b are matched
+//     /test/*         prefix match, /test/, /test/abc and /test/123/abc are matched, /test is not
+//
+// Regex patterns are matched against the whole url path, e.g. "GET:~/v[0-9]+/ping", as if they were wrapped in "^(?:...)$"
+
+const (
+	segmentLiteral = iota
+	segmentParam
+	segmentStar
+	segmentDoubleStar
+	segmentTail
+)
+
+var httpMethods = map[string]struct{}{
+	http.MethodGet:     {},
+	http.MethodHead:    {},
+	http.MethodPost:    {},
+	http.MethodPut:     {},
+	http.MethodPatch:   {},
+	http.MethodDelete:  {},
+	http.MethodConnect: {},
+	http.MethodOptions: {},
+	http.MethodTrace:   {},
+	MethodAll:          {},
+}
+
+// methodSet is nil when any method is allowed
+type methodSet map[string]struct{}
+
+func (s methodSet) contains(method string) bool {
+	if s == nil {
+		return true
+	}
+	_, ok := s[method]
+	return ok
+}
+
+type matcherNode struct {
+	literals   map[string]*matcherNode
+	param      *matcherNode
+	star       *matcherNode
+	doubleStar *matcherNode
+	tail       *matcherNode
+	terminal   bool
+	methods    methodSet
+	paramName  string // name of the param or tail segment leading to this node, e.g. "id" of "{id}"
+}
+
+type regexRule struct {
+	methods methodSet
+	regex   *regexp.Regexp
+}
+
+type pathMatcher struct {
+	root          *matcherNode
+	regexes       []regexRule
+	hasDoubleStar bool // failed walks are only memoized with "**", which may visit a node at the same segment many times
+}
+
+// matchState is the state of a walk of the trie
+type matchState struct {
+	segments []string
+	method   string
+	params   map[string]string
+	failed   map[matchKey]struct{} // nil unless the trie has "**"
+}
+
+type matchKey struct {
+	node  *matcherNode
+	index int
+}
+
+// newPathMatcher compiles rules into a trie. Regex rules are kept in a list and checked after the trie.
+func newPathMatcher(rules []string) (*pathMatcher, error) {
+	pm := &pathMatcher{root: &matcherNode{}}
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		methods, pattern := splitMethodPattern(rule)
+		if strings.HasPrefix(pattern, PrefixRegexPattern) {
+			expr := pattern[len(PrefixRegexPattern):]
+			// compiled alone first, so an unbalanced expr like "a)|(b" cannot break out of the anchors
+			if _, err := regexp.Compile(expr); err != nil {
+				return nil, fmt.Errorf("%s %q: %w", errorInvalidPattern, rule, err)
+			}
+			// anchored, so "~/admin" does not match "/public/admin" or "/admin/users"
+			regex := regexp.MustCompile("^(?:" + expr + ")$")
+			pm.regexes = append(pm.regexes, regexRule{methods: methods, regex: regex})
+			continue
+		}
+		if !strings.HasPrefix(pattern, "/") {
+			return nil, fmt.Errorf("%s %q: path must start with \"/\"", errorInvalidPattern, rule)
+		}
+		pm.insert(splitPath(pattern), methods)
+	}
+	return pm, nil
+}
+
+// splitMethodPattern splits "GET,HEAD:/docs/*" into methods and pattern.
+// The part before the first ":" is only treated as methods if every item is a known http method,
+// so a path containing a colon like "/user/:id" or "/a:b" is kept as is.
+func splitMethodPattern(rule string) (methodSet, string) {
+	index := strings.Index(rule, ":")
+	if index <= 0 {
+		return nil, rule
+	}
+	methods := methodSet{}
+	for _, item := range strings.Split(rule[:index], ",") {
+		method := strings.ToUpper(strings.TrimSpace(item)) // force to be POST, PUT, etc.
+		if _, ok := httpMethods[method]; !ok {
+			return nil, rule
+		}
+		if method == MethodAll {
+			methods = nil
+			break
+		}
+		methods[method] = struct{}{}
+	}
+	return methods, strings.TrimSpace(rule[index+1:])
+}
+
+func splitPath(urlPath string) []string {
+	return strings.Split(strings.TrimPrefix(urlPath, "/"), "/")
+}
+
+func segmentKind(segment string, last bool) int {
+	switch {
+	case segment == "**":
+		return segmentDoubleStar
+	case strings.HasPrefix(segment, "*"):
+		// "*" and GoFrame style "*any" consume the rest of the path when they are the last segment
+		if last {
+			return segmentTail
+		}
+		return segmentStar
+	case strings.HasPrefix(segment, ":") && len(segment) > 1:
+		return segmentParam
+	case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") && len(segment) > 2:
+		return segmentParam
+	default:
+		return segmentLiteral
+	}
+}
+
+func (pm *pathMatcher) insert(segments []string, methods methodSet) {
+	node := pm.root
+	for i, segment := range segments {
+		var next **matcherNode
+		switch segmentKind(segment, i == len(segments)-1) {
+		case segmentParam:
+			next = &node.param
+		case segmentStar:
+			next = &node.star
+		case segmentDoubleStar:
+			next = &node.doubleStar
+			pm.hasDoubleStar = true
+		case segmentTail:
+			next = &node.tail
+		default:
+			if node.literals == nil {
+				node.literals = map[string]*matcherNode{}
+			}
+			child, ok := node.literals[segment]
+			if !ok {
+				child = &matcherNode{}
+				node.literals[segment] = child
+			}
+			node = child
+			continue
+		}
+		if *next == nil {
+			*next = &matcherNode{}
+		}
+		node = *next
+		node.paramName = strings.Trim(segment, "{}:*")
+	}
+	node.addMethods(methods)
+}
+
+func (n *matcherNode) addMethods(methods methodSet) {
+	switch {
+	case n.terminal && n.methods == nil:
+		// already matches any method
+	case !n.terminal || methods == nil:
+		n.terminal = true
+		n.methods = methods
+	default:
+		for k := range methods {
+			n.methods[k] = struct{}{}
+		}
+	}
+}
+
+// matches reports whether the given path and method are matched by any rule
+func (pm *pathMatcher) matches(urlPath string, urlMethod string) bool {
+	if pm == nil {
+		return false
+	}
+	urlMethod = strings.ToUpper(urlMethod) // ensure to be POST, PUT, etc.
+	if pm.walk(urlPath, urlMethod, nil) {
+		return true
+	}
+	for _, item := range pm.regexes {
+		if item.methods.contains(urlMethod) && item.regex.MatchString(urlPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchParams is like matches, but also returns the values of named segments, e.g. {"id": "42"} of "/user/{id}".
+// Regex rules do not capture params. It is meant for a matcher with a single rule,
+// since rules sharing a trie node share the name of its param.
+func (pm *pathMatcher) matchParams(urlPath string, urlMethod string) (map[string]string, bool) {
+	if pm == nil {
+		return nil, false
+	}
+	params := map[string]string{}
+	if pm.walk(urlPath, strings.ToUpper(urlMethod), params) {
+		return params, true
+	}
+	return nil, pm.matches(urlPath, urlMethod)
+}
+
+// walk matches the path against the trie. If params is not nil, values of named segments are captured into it.
+func (pm *pathMatcher) walk(urlPath string, method string, params map[string]string) bool {
+	state := &matchState{segments: splitPath(urlPath), method: method, params: params}
+	if pm.hasDoubleStar {
+		state.failed = map[matchKey]struct{}{}
+	}
+	return pm.root.match(state, 0)
+}
+
+// match walks the trie from the segment at index. With "**", a failed walk of a node at an index is remembered,
+// since it fails again whatever way it is reached, so "/**/a/**/b/**/c" cannot take exponential time.
+func (n *matcherNode) match(state *matchState, index int) bool {
+	if state.failed != nil {
+		if _, ok := state.failed[matchKey{n, index}]; ok {
+			return false
+		}
+	}
+	if n.matchAt(state, index) {
+		return true
+	}
+	if state.failed != nil {
+		state.failed[matchKey{n, index}] = struct{}{}
+	}
+	return false
+}
+
+func (n *matcherNode) matchAt(state *matchState, index int) bool {
+	segments := state.segments[index:]
+	if len(segments) == 0 {
+		if n.terminal && n.methods.contains(state.method) {
+			return true
+		}
+		// "**" can match zero segments
+		return n.doubleStar != nil && n.doubleStar.match(state, index)
+	}
+	// priority: literal > param > star > double star > tail
+	if child, ok := n.literals[segments[0]]; ok && child.match(state, index+1) {
+		return true
+	}
+	if segments[0] != "" {
+		if n.param != nil && n.param.capture(segments[0], state.params) && n.param.match(state, index+1) {
+			return true
+		}
+		if n.star != nil && n.star.match(state, index+1) {
+			return true
+		}
+	}
+	if n.doubleStar != nil {
+		for i := index; i <= len(state.segments); i++ {
+			if n.doubleStar.match(state, i) {
+				return true
+			}
+		}
+	}
+	if n.tail != nil && n.tail.terminal && n.tail.methods.contains(state.method) {
+		n.tail.capture(strings.Join(segments, "/"), state.params)
+		return true
+	}
+	return false
+}
+
+// capture saves the value of a named segment. It always returns true to be chained in conditions.
+func (n *matcherNode) capture(value string, params map[string]string) bool {
+	if params != nil && n.paramName != "" {
+		params[n.paramName] = value
+	}
+	return true
+}
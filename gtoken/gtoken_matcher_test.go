@@ -0,0 +1,114 @@
+package gtoken
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPathMatcher(t *testing.T) {
+	t.Log("test: path matcher")
+
+	matcher, err := newPathMatcher([]string{
+		"/login",               // 1. literal
+		"GET,HEAD:/docs/*",     // 2. method list with prefix match
+		"/user/{id}",           // 3. GoFrame style param
+		"DELETE:/order/:id",    // 4. GoFrame style param with method
+		"/shop/*/items",        // 5. single segment wildcard
+		"/static/**/raw",       // 6. multi segments wildcard
+		"/files/**",            // 7. trailing multi segments wildcard
+		"GET:~^/v[0-9]+/ping$", // 8. regex
+		"/a:b",                 // 9. path containing a colon
+		"all:/any",             // 10. any method
+	})
+	if err != nil {
+		t.Fatal("error:", err)
+	}
+
+	cases := []struct {
+		path    string
+		method  string
+		matched bool
+	}{
+		{"/login", "post", true},
+		{"/login/", "post", false},
+		{"/docs/", "GET", true},
+		{"/docs/a/b", "head", true},
+		{"/docs/a", "post", false},
+		{"/docs", "get", false},
+		{"/user/42", "put", true},
+		{"/user/", "get", false},
+		{"/user/42/name", "get", false},
+		{"/order/7", "delete", true},
+		{"/order/7", "get", false},
+		{"/shop/1/items", "get", true},
+		{"/shop/1/2/items", "get", false},
+		{"/shop//items", "get", false},
+		{"/static/raw", "get", true},
+		{"/static/a/b/raw", "get", true},
+		{"/static/a/b", "get", false},
+		{"/files", "get", true},
+		{"/files/a/b", "get", true},
+		{"/v2/ping", "get", true},
+		{"/v2/ping", "post", false},
+		{"/vx/ping", "get", false},
+		{"/a:b", "get", true},
+		{"/any", "patch", true},
+		{"/unknown", "get", false},
+	}
+	for _, c := range cases {
+		if matcher.matches(c.path, c.method) != c.matched {
+			t.Errorf("error: %s:%s should be matched=%v", c.method, c.path, c.matched)
+		}
+	}
+
+	t.Log("test: regex patterns are anchored")
+	matcher, err = newPathMatcher([]string{"~/admin", "~/v[0-9]+/.*"})
+	if err != nil {
+		t.Fatal("error:", err)
+	}
+	for path, matched := range map[string]bool{
+		"/admin":        true,
+		"/admin/users":  false,
+		"/public/admin": false,
+		"/v1/ping":      true,
+		"/api/v1/ping":  false,
+	} {
+		if matcher.matches(path, "get") != matched {
+			t.Errorf("error: %s should be matched=%v", path, matched)
+		}
+	}
+
+	t.Log("test: many \"**\" segments do not backtrack exponentially")
+	matcher, err = newPathMatcher([]string{"/**/a/**/a/**/a/**/a/**/a/**/a/**/b"})
+	if err != nil {
+		t.Fatal("error:", err)
+	}
+	path := strings.Repeat("/a", 200)
+	done := make(chan bool, 1)
+	go func() {
+		done <- matcher.matches(path, "get")
+	}()
+	select {
+	case matched := <-done:
+		if matched {
+			t.Error("error:", path, "should not be matched")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("error: matching takes too long")
+	}
+	if !matcher.matches(path+"/b", "get") {
+		t.Error("error:", path+"/b", "should be matched")
+	}
+
+	t.Log("test: invalid patterns")
+	if _, err = newPathMatcher([]string{"~[a-"}); err == nil {
+		t.Error("error:", "invalid regex is not detected")
+	}
+	if _, err = newPathMatcher([]string{"~a)|(b"}); err == nil {
+		t.Error("error:", "unbalanced regex is not detected")
+	}
+	if _, err = newPathMatcher([]string{"GET:login"}); err == nil {
+		t.Error("error:", "path without leading slash is not detected")
+	}
+}
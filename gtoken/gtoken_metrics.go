@@ -0,0 +1,187 @@
+package gtoken
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gogf/gf/v2/os/gcache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// tokenMetrics holds the otel instruments of token operations. It is shared by tenant views.
+type tokenMetrics struct {
+	issued        metric.Int64Counter
+	validations   metric.Int64Counter
+	refreshes     metric.Int64Counter
+	revocations   metric.Int64Counter
+	storeDuration metric.Float64Histogram
+}
+
+// initMetrics creates instruments by MeterProvider, or the global one registered by otel.SetMeterProvider
+func (m *GToken) initMetrics() (err error) {
+	provider := m.MeterProvider
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	meter := provider.Meter(InstrumentationName)
+	metrics := &tokenMetrics{}
+	if metrics.issued, err = meter.Int64Counter(
+		MetricTokensIssued,
+		metric.WithDescription("Number of tokens issued by NewToken"),
+	); err != nil {
+		return err
+	}
+	if metrics.validations, err = meter.Int64Counter(
+		MetricValidations,
+		metric.WithDescription("Number of token validations by outcome and reason"),
+	); err != nil {
+		return err
+	}
+	if metrics.refreshes, err = meter.Int64Counter(
+		MetricRefreshes,
+		metric.WithDescription("Number of tokens refreshed by AutoRefreshToken"),
+	); err != nil {
+		return err
+	}
+	if metrics.revocations, err = meter.Int64Counter(
+		MetricRevocations,
+		metric.WithDescription("Number of tokens removed by reason"),
+	); err != nil {
+		return err
+	}
+	if metrics.storeDuration, err = meter.Float64Histogram(
+		MetricStoreDuration,
+		metric.WithDescription("Duration of token store operations by backend"),
+		metric.WithUnit("s"),
+	); err != nil {
+		return err
+	}
+	// counting tokens scans the whole store at every collection, so the gauge is opt-in
+	if m.SessionsGauge {
+		if _, err = meter.Int64ObservableGauge(
+			MetricSessionsActive,
+			metric.WithDescription("Number of tokens in the store"),
+			metric.WithInt64Callback(func(ctx context.Context, observer metric.Int64Observer) error {
+				count, err1 := m.countTokens(ctx)
+				if err1 != nil {
+					return err1
+				}
+				observer.Observe(count, metric.WithAttributes(attribute.String(MetricAttrBackend, m.backendName())))
+				return nil
+			}),
+		); err != nil {
+			return err
+		}
+	}
+	m.metrics = metrics
+	return nil
+}
+
+func (m *GToken) recordIssued(ctx context.Context) {
+	if m.metrics == nil {
+		return
+	}
+	m.metrics.issued.Add(ctx, 1, metric.WithAttributes(attribute.String(MetricAttrTenant, m.tenantID)))
+}
+
+func (m *GToken) recordValidation(ctx context.Context, err error) {
+	if m.metrics == nil {
+		return
+	}
+	attrs := []attribute.KeyValue{attribute.String(MetricAttrTenant, m.tenantID)}
+	if err == nil {
+		attrs = append(attrs, attribute.String(MetricAttrOutcome, MetricOutcomeSuccess))
+	} else {
+		attrs = append(attrs,
+			attribute.String(MetricAttrOutcome, MetricOutcomeFailure),
+			attribute.String(MetricAttrReason, validationReason(err)),
+		)
+	}
+	m.metrics.validations.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+func (m *GToken) recordRefresh(ctx context.Context) {
+	if m.metrics == nil {
+		return
+	}
+	m.metrics.refreshes.Add(ctx, 1, metric.WithAttributes(attribute.String(MetricAttrTenant, m.tenantID)))
+}
+
+func (m *GToken) recordRevocations(ctx context.Context, reason string, count int) {
+	if m.metrics == nil || count == 0 {
+		return
+	}
+	m.metrics.revocations.Add(ctx, int64(count), metric.WithAttributes(
+		attribute.String(MetricAttrTenant, m.tenantID),
+		attribute.String(MetricAttrReason, reason),
+	))
+}
+
+// observeStore records the duration of a store operation since start, called by traceStore
+func (m *GToken) observeStore(ctx context.Context, operation string, start time.Time) {
+	if m.metrics == nil {
+		return
+	}
+	m.metrics.storeDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String(MetricAttrBackend, m.backendName()),
+		attribute.String(MetricAttrOperation, operation),
+	))
+}
+
+func (m *GToken) backendName() string {
+	switch m.CacheMode {
+	case CacheModeCache:
+		return "cache"
+	case CacheModeRedis:
+		return "redis"
+	case CacheModeFile:
+		return "file"
+	default:
+		return "unknown"
+	}
+}
+
+// validationReason keeps the reason attribute low-cardinality, since errors of backends may carry any text
+func validationReason(err error) string {
+	switch msg := err.Error(); msg {
+	case errorTokenEmpty, errorTokenDecode, errorTokenNotFound, errorTenantMismatch, errorTenantNotFound:
+		return msg
+	default:
+		return errorUseCache
+	}
+}
+
+// countTokens counts token keys of all tenants in the store
+func (m *GToken) countTokens(ctx context.Context) (int64, error) {
+	var count int64
+	switch m.CacheMode {
+	case CacheModeCache, CacheModeFile:
+		keys, err := gcache.KeyStrings(ctx)
+		if err != nil {
+			return 0, err
+		}
+		for _, key := range keys {
+			if isTokenKey(key) {
+				count++
+			}
+		}
+	case CacheModeRedis:
+		err := m.scanRedisKeys(ctx, "*"+DefaultPrefixToken+"*", func(keys []string) error {
+			for _, key := range keys {
+				if isTokenKey(key) {
+					count++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, errors.New(errorInvalidMode)
+	}
+	return count, nil
+}
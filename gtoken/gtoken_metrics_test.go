@@ -0,0 +1,122 @@
+package gtoken_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mayugene/gtoken/gtoken"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Log("test: otel metrics of token operations")
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	gToken := &gtoken.GToken{MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), SessionsGauge: true}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+
+	token, _, err := gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = gToken.ValidateToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = gToken.ValidateToken(ctx, "invalid"); err == nil {
+		t.Fatal("an invalid token should fail")
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err = reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	metrics := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	// sum returns the sum of data points with the attribute, or of all if key is ""
+	sum := func(name string, key string, value string) int64 {
+		var total int64
+		switch data := metrics[name].(type) {
+		case metricdata.Sum[int64]:
+			for _, dp := range data.DataPoints {
+				if v, ok := dp.Attributes.Value(attribute.Key(key)); key == "" || ok && v.AsString() == value {
+					total += dp.Value
+				}
+			}
+		case metricdata.Gauge[int64]:
+			for _, dp := range data.DataPoints {
+				total += dp.Value
+			}
+		default:
+			t.Error("error: metric is not found:", name)
+		}
+		return total
+	}
+
+	t.Log("1. issued and validations by outcome")
+	if n := sum(gtoken.MetricTokensIssued, "", ""); n != 1 {
+		t.Error("error: issued should be 1, but:", n)
+	}
+	if n := sum(gtoken.MetricValidations, gtoken.MetricAttrOutcome, gtoken.MetricOutcomeSuccess); n != 1 {
+		t.Error("error: successful validations should be 1, but:", n)
+	}
+	if n := sum(gtoken.MetricValidations, gtoken.MetricAttrOutcome, gtoken.MetricOutcomeFailure); n != 1 {
+		t.Error("error: failed validations should be 1, but:", n)
+	}
+
+	t.Log("2. active sessions")
+	if n := sum(gtoken.MetricSessionsActive, "", ""); n < 1 {
+		t.Error("error: active sessions should be at least 1, but:", n)
+	}
+	// the gauge scans the store, so it is only registered by SessionsGauge
+	defaultReader := sdkmetric.NewManualReader()
+	defaultToken := &gtoken.GToken{MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(defaultReader))}
+	if !defaultToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	var defaultRM metricdata.ResourceMetrics
+	if err = defaultReader.Collect(ctx, &defaultRM); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range defaultRM.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == gtoken.MetricSessionsActive {
+				t.Error("error: active sessions should not be observed by default")
+			}
+		}
+	}
+
+	t.Log("3. store latency by backend")
+	histogram, ok := metrics[gtoken.MetricStoreDuration].(metricdata.Histogram[float64])
+	if !ok || len(histogram.DataPoints) == 0 {
+		t.Fatal("error: store duration is not recorded")
+	}
+	for _, dp := range histogram.DataPoints {
+		if v, _ := dp.Attributes.Value(gtoken.MetricAttrBackend); v.AsString() != "cache" {
+			t.Error("error: backend should be cache, but:", v.AsString())
+		}
+	}
+
+	t.Log("4. revocations by reason")
+	if _, err = gToken.RemoveToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if err = reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	if n := sum(gtoken.MetricRevocations, gtoken.MetricAttrReason, gtoken.ReasonTokenRevoked); n != 1 {
+		t.Error("error: revocations should be 1, but:", n)
+	}
+}
@@ -0,0 +1,134 @@
+package gtoken
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/util/gconv"
+)
+
+// Policy makes attribute-based decisions after a token is validated.
+// params are the route params of the matched handler, e.g. {"id": "42"} of "/tenants/{id}/*".
+// reason is sent to the client by DoForbidden when allow is false.
+type Policy interface {
+	Evaluate(r *http.Request, params map[string]string, tokenInfo *TokenInfo) (allow bool, reason string)
+}
+
+// PolicyFunc is an adapter to use an ordinary func as a Policy
+type PolicyFunc func(r *http.Request, params map[string]string, tokenInfo *TokenInfo) (allow bool, reason string)
+
+func (f PolicyFunc) Evaluate(r *http.Request, params map[string]string, tokenInfo *TokenInfo) (allow bool, reason string) {
+	return f(r, params, tokenInfo)
+}
+
+// PolicyRule compares a claim of the token with a path param, e.g.
+//
+//	PolicyRule{Path: "/tenants/{id}/*", Claim: "tenantId", Param: "id"}
+//
+// only lets sessions whose ExtraData.tenantId equals the id in the path go on.
+type PolicyRule struct {
+	Path  string // same formats as PublicPaths, e.g. "GET:/tenants/{id}/*"
+	Claim string // "userID", "tokenID", or a key of ExtraData. Nested keys are separated by ".", e.g. "org.id"
+	Param string // name of the path param compared with the claim
+	Op    string // PolicyOpEqual (default) or PolicyOpContains
+}
+
+// RulePolicy is a small built-in Policy. A request must satisfy every rule whose Path is matched.
+type RulePolicy struct {
+	rules    []PolicyRule
+	matchers []*pathMatcher
+}
+
+func NewRulePolicy(rules ...PolicyRule) (*RulePolicy, error) {
+	p := &RulePolicy{rules: rules, matchers: make([]*pathMatcher, 0, len(rules))}
+	for _, rule := range rules {
+		switch rule.Op {
+		case "", PolicyOpEqual, PolicyOpContains:
+		default:
+			return nil, fmt.Errorf("%s %q", errorInvalidPolicyOp, rule.Op)
+		}
+		matcher, err := newPathMatcher([]string{rule.Path})
+		if err != nil {
+			return nil, err
+		}
+		p.matchers = append(p.matchers, matcher)
+	}
+	return p, nil
+}
+
+func (p *RulePolicy) Evaluate(r *http.Request, params map[string]string, tokenInfo *TokenInfo) (allow bool, reason string) {
+	for i, rule := range p.rules {
+		pathParams, ok := p.matchers[i].matchParams(r.URL.Path, r.Method)
+		if !ok {
+			continue
+		}
+		// params captured by the rule take priority, so a handler param of the same name at another position cannot bypass it
+		value, ok := pathParams[rule.Param]
+		if !ok {
+			value, ok = params[rule.Param]
+		}
+		if !ok {
+			return false, fmt.Sprintf("path param %q not found", rule.Param)
+		}
+		if !rule.satisfied(claimValue(tokenInfo, rule.Claim), value) {
+			return false, fmt.Sprintf("claim %q does not match path param %q", rule.Claim, rule.Param)
+		}
+	}
+	return true, ""
+}
+
+func (rule PolicyRule) satisfied(claim interface{}, value string) bool {
+	if claim == nil {
+		return false
+	}
+	if rule.Op == PolicyOpContains {
+		for _, item := range gconv.Strings(claim) {
+			if item == value {
+				return true
+			}
+		}
+		return false
+	}
+	return gconv.String(claim) == value
+}
+
+func claimValue(tokenInfo *TokenInfo, claim string) interface{} {
+	switch claim {
+	case ClaimUserID:
+		return tokenInfo.UserID
+	case ClaimTokenID:
+		return tokenInfo.TokenID
+	}
+	if tokenInfo.ExtraData == nil {
+		return nil
+	}
+	return gjson.New(tokenInfo.ExtraData).Get(claim).Val()
+}
+
+// checkPolicy writes 403 by DoForbidden if the Policy denies the session
+func (m *GToken) checkPolicy(r *ghttp.Request, tokenInfo *TokenInfo) bool {
+	if m.Policy == nil {
+		return true
+	}
+	allow, reason := m.evaluatePolicy(r, tokenInfo)
+	if allow {
+		return true
+	}
+	m.DoForbidden(r, g.Map{"reason": reason})
+	return false
+}
+
+// evaluatePolicy evaluates Policy with the params of the handler, without writing a response
+func (m *GToken) evaluatePolicy(r *ghttp.Request, tokenInfo *TokenInfo) (allow bool, reason string) {
+	if m.Policy == nil {
+		return true, ""
+	}
+	var params map[string]string
+	if handler := r.GetServeHandler(); handler != nil {
+		params = handler.Values
+	}
+	return m.Policy.Evaluate(r.Request, params, tokenInfo)
+}
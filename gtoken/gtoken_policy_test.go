@@ -0,0 +1,116 @@
+package gtoken
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+func TestRulePolicy(t *testing.T) {
+	t.Log("test: rule policy")
+	policy, err := NewRulePolicy(
+		PolicyRule{Path: "/tenants/{id}/*", Claim: "tenantId", Param: "id"},
+		PolicyRule{Path: "/orgs/:org/**", Claim: "org.ids", Param: "org", Op: PolicyOpContains},
+		PolicyRule{Path: "DELETE:/users/{uid}", Claim: ClaimUserID, Param: "uid"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenInfo := &TokenInfo{
+		UserID:    "u1",
+		ExtraData: g.Map{"tenantId": 7, "org": g.Map{"ids": []string{"a", "b"}}},
+	}
+	cases := []struct {
+		method string
+		path   string
+		allow  bool
+	}{
+		{http.MethodGet, "/tenants/7/orders", true},
+		{http.MethodGet, "/tenants/8/orders", false},
+		{http.MethodGet, "/orgs/b", true},
+		{http.MethodGet, "/orgs/c/members", false},
+		{http.MethodDelete, "/users/u1", true},
+		{http.MethodDelete, "/users/u2", false},
+		{http.MethodGet, "/users/u2", true}, // no rule is matched
+	}
+	for _, c := range cases {
+		allow, reason := policy.Evaluate(httptest.NewRequest(c.method, c.path, nil), nil, tokenInfo)
+		if allow != c.allow {
+			t.Errorf("error: %s:%s should be allow=%v, reason: %s", c.method, c.path, c.allow, reason)
+		}
+	}
+
+	t.Log("test: params captured by the rule take priority over route params of the matched handler")
+	tenantPolicy, err := NewRulePolicy(PolicyRule{Path: "/tenants/{id}/**", Claim: "tenantId", Param: "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the handler route is /tenants/{tenant}/items/{id}, its id is not the tenant
+	allow, _ := tenantPolicy.Evaluate(httptest.NewRequest(http.MethodGet, "/tenants/8/items/7", nil), map[string]string{"tenant": "8", "id": "7"}, tokenInfo)
+	if allow {
+		t.Error("error: route params should not override params captured by the rule")
+	}
+	allow, _ = policy.Evaluate(httptest.NewRequest(http.MethodGet, "/orgs/b", nil), map[string]string{"org": "c"}, tokenInfo)
+	if !allow {
+		t.Error("error: params captured by the rule are not used")
+	}
+
+	t.Log("test: invalid op")
+	if _, err = NewRulePolicy(PolicyRule{Path: "/a", Op: "gt"}); err == nil {
+		t.Error("error: invalid op is not detected")
+	}
+}
+
+func TestPolicyMiddleware(t *testing.T) {
+	t.Log("test: policy in auth middleware")
+	ctx := context.Background()
+	policy, err := NewRulePolicy(PolicyRule{Path: "/tenants/**", Claim: "tenantId", Param: "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gToken := &GToken{Policy: policy}
+
+	s := g.Server("policy")
+	s.SetPort(8085)
+	s.Group("/", func(group *ghttp.RouterGroup) {
+		err1 := gToken.UseMiddleware(ctx, group)
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		group.GET("/tenants/{id}/orders", func(r *ghttp.Request) {
+			r.Response.WriteJson(DefaultResponse{Msg: "ok"})
+		})
+	})
+	err = s.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Shutdown()
+	}()
+
+	token, _, err := gToken.NewToken(ctx, "tenant-user", g.Map{"tenantId": "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	getResponse := func(path string) *DefaultResponse {
+		client := g.Client()
+		client.SetHeader("Authorization", PrefixBearer+token)
+		res := DefaultResponse{}
+		if err1 := gjson.DecodeTo(client.GetContent(ctx, "http://127.0.0.1:8085"+path), &res); err1 != nil {
+			t.Error("error:", err1)
+		}
+		return &res
+	}
+	if res := getResponse("/tenants/7/orders"); res.Code != DefaultCodeOK {
+		t.Error("error:", res)
+	}
+	if res := getResponse("/tenants/8/orders"); res.Code != DefaultCodeForbidden {
+		t.Error("error:", res)
+	}
+}
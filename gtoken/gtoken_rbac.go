@@ -0,0 +1,175 @@
+package gtoken
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+// RBAC is a role -> permission registry. It is safe for concurrent use.
+//
+// Permissions are strings separated by ":", like "orders:write".
+// A "*" segment in a granted permission matches any segment, and a trailing "*" matches the rest, so:
+//
+//	"*"          matches every permission
+//	"orders:*"   matches "orders:read", "orders:write" and "orders:write:own"
+//	"*:read"     matches "orders:read" and "users:read"
+//
+// A role inherits all permissions of its parents.
+type RBAC struct {
+	mu      sync.RWMutex
+	grants  map[string][]string // role -> permissions
+	parents map[string][]string // role -> parent roles
+}
+
+func NewRBAC() *RBAC {
+	return &RBAC{
+		grants:  map[string][]string{},
+		parents: map[string][]string{},
+	}
+}
+
+// Grant adds permissions to a role
+func (rb *RBAC) Grant(role string, permissions ...string) *RBAC {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.grants[role] = append(rb.grants[role], permissions...)
+	return rb
+}
+
+// Inherit makes role inherit all permissions of parents. A cycle of inheritance is rejected.
+func (rb *RBAC) Inherit(role string, parents ...string) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	for _, parent := range parents {
+		if parent == role || rb.reaches(parent, role, map[string]bool{}) {
+			return fmt.Errorf("%s: %s -> %s", errorRoleCycle, role, parent)
+		}
+	}
+	rb.parents[role] = append(rb.parents[role], parents...)
+	return nil
+}
+
+// reaches reports whether target is from or one of its ancestors
+func (rb *RBAC) reaches(from string, target string, visited map[string]bool) bool {
+	if from == target {
+		return true
+	}
+	if visited[from] {
+		return false
+	}
+	visited[from] = true
+	for _, parent := range rb.parents[from] {
+		if rb.reaches(parent, target, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions returns all permissions of the given roles, including inherited ones
+func (rb *RBAC) Permissions(roles ...string) []string {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	var (
+		permissions []string
+		visited     = map[string]bool{}
+	)
+	var collect func(role string)
+	collect = func(role string) {
+		if visited[role] {
+			return
+		}
+		visited[role] = true
+		permissions = append(permissions, rb.grants[role]...)
+		for _, parent := range rb.parents[role] {
+			collect(parent)
+		}
+	}
+	for _, role := range roles {
+		collect(role)
+	}
+	return permissions
+}
+
+// HasPermission reports whether any of the given roles is granted the permission
+func (rb *RBAC) HasPermission(roles []string, permission string) bool {
+	for _, granted := range rb.Permissions(roles...) {
+		if matchPermission(granted, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPermission(granted string, required string) bool {
+	grantedSegments := strings.Split(granted, ":")
+	requiredSegments := strings.Split(required, ":")
+	for i, segment := range grantedSegments {
+		if i >= len(requiredSegments) {
+			return false
+		}
+		if segment == "*" && i == len(grantedSegments)-1 {
+			return true
+		}
+		if segment != "*" && segment != requiredSegments[i] {
+			return false
+		}
+	}
+	return len(grantedSegments) == len(requiredSegments)
+}
+
+// HasPermission reports whether the authenticated session in ctx is granted all the permissions
+func (m *GToken) HasPermission(ctx context.Context, permissions ...string) bool {
+	tokenInfo, ok := FromContext(ctx)
+	if !ok || m.RBAC == nil {
+		return false
+	}
+	for _, permission := range permissions {
+		if !m.RBAC.HasPermission(tokenInfo.Roles, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// Require returns a middleware which only lets sessions with all the permissions go on.
+// It should be used after UseMiddleware. Anonymous requests get 401, and sessions lacking permissions get 403.
+//
+//	group.Middleware(gToken.Require("orders:write"))
+func (m *GToken) Require(permissions ...string) ghttp.HandlerFunc {
+	return func(r *ghttp.Request) {
+		if _, ok := FromContext(r.Context()); !ok {
+			m.DoAfterAuth(r, false, nil)
+			return
+		}
+		if m.RBAC == nil {
+			m.log(r.Context(), LogLevelWarning, errorRBACNotSet)
+		}
+		if !m.HasPermission(r.Context(), permissions...) {
+			m.DoForbidden(r, g.Map{"reason": ReasonPermissionDenied, "required": permissions})
+			return
+		}
+		r.Middleware.Next()
+	}
+}
+
+// PermissionPolicy returns a Policy which only allows sessions granted all the permissions by RBAC, e.g. for MountAdmin
+func (m *GToken) PermissionPolicy(permissions ...string) Policy {
+	return PolicyFunc(func(r *http.Request, params map[string]string, tokenInfo *TokenInfo) (bool, string) {
+		if m.RBAC == nil {
+			return false, errorRBACNotSet
+		}
+		for _, permission := range permissions {
+			if !m.RBAC.HasPermission(tokenInfo.Roles, permission) {
+				return false, ReasonPermissionDenied
+			}
+		}
+		return true, ""
+	})
+}
@@ -0,0 +1,119 @@
+package gtoken
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+func TestMatchPermission(t *testing.T) {
+	t.Log("test: match permission")
+	cases := []struct {
+		granted  string
+		required string
+		matched  bool
+	}{
+		{"orders:write", "orders:write", true},
+		{"orders:write", "orders:read", false},
+		{"orders:*", "orders:write", true},
+		{"orders:*", "orders:write:own", true},
+		{"orders:*", "orders", false},
+		{"*:read", "users:read", true},
+		{"*:read", "users:write", false},
+		{"*", "anything:at:all", true},
+		{"orders", "orders:write", false},
+	}
+	for _, c := range cases {
+		if matchPermission(c.granted, c.required) != c.matched {
+			t.Errorf("error: %s -> %s should be matched=%v", c.granted, c.required, c.matched)
+		}
+	}
+}
+
+func TestRBAC(t *testing.T) {
+	t.Log("test: role inheritance")
+	rbac := NewRBAC().
+		Grant("viewer", "orders:read").
+		Grant("editor", "orders:write").
+		Grant("admin", "users:*")
+	if err := rbac.Inherit("editor", "viewer"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rbac.Inherit("admin", "editor"); err != nil {
+		t.Fatal(err)
+	}
+	if !rbac.HasPermission([]string{"admin"}, "orders:read") {
+		t.Error("error:", "admin should inherit orders:read from viewer")
+	}
+	if rbac.HasPermission([]string{"viewer"}, "orders:write") {
+		t.Error("error:", "viewer should not have orders:write")
+	}
+	if !rbac.HasPermission([]string{"viewer", "admin"}, "users:delete") {
+		t.Error("error:", "permissions of all roles should be checked")
+	}
+	if err := rbac.Inherit("viewer", "admin"); err == nil {
+		t.Error("error:", "inheritance cycle is not detected")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	t.Log("test: require permissions")
+	ctx := context.Background()
+	gToken := &GToken{RBAC: NewRBAC().Grant("editor", "orders:*")}
+
+	s := g.Server("require")
+	s.SetPort(8083)
+	s.Group("/", func(group *ghttp.RouterGroup) {
+		err := gToken.UseMiddleware(ctx, group)
+		if err != nil {
+			t.Fatal(err)
+		}
+		group.Middleware(gToken.Require("orders:write"))
+		group.POST("/orders", func(r *ghttp.Request) {
+			r.Response.WriteJson(DefaultResponse{Msg: "ok"})
+		})
+	})
+	err := s.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Shutdown()
+	}()
+
+	postCode := func(token string) int {
+		client := g.Client()
+		if token != "" {
+			client.SetHeader("Authorization", PrefixBearer+token)
+		}
+		res := DefaultResponse{}
+		if err1 := gjson.DecodeTo(client.PostContent(ctx, "http://127.0.0.1:8083/orders"), &res); err1 != nil {
+			t.Error("error:", err1)
+		}
+		return res.Code
+	}
+
+	t.Log("1. anonymous request gets 401")
+	if code := postCode(""); code != DefaultCodeUnauthorized {
+		t.Errorf("code should be %d, but: %d", DefaultCodeUnauthorized, code)
+	}
+	t.Log("2. session without permission gets 403")
+	viewerToken, _, err := gToken.NewToken(ctx, "viewer-user", nil, WithRoles("viewer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := postCode(viewerToken); code != DefaultCodeForbidden {
+		t.Errorf("code should be %d, but: %d", DefaultCodeForbidden, code)
+	}
+	t.Log("3. session with wildcard permission goes on")
+	editorToken, _, err := gToken.NewToken(ctx, "editor-user", nil, WithRoles("editor"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := postCode(editorToken); code != DefaultCodeOK {
+		t.Errorf("code should be %d, but: %d", DefaultCodeOK, code)
+	}
+}
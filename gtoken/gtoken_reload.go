@@ -0,0 +1,184 @@
+package gtoken
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcfg"
+)
+
+// settings is a snapshot of the reloadable settings, swapped atomically by Reload.
+// A request reads it once, so it never sees half of an old config and half of a new one.
+type settings struct {
+	config              Config
+	publicMatcher       *pathMatcher // compiled PublicPaths
+	optionalMatcher     *pathMatcher // compiled OptionalPaths
+	publicMethodMatcher *pathMatcher // compiled PublicMethods
+}
+
+func newSettings(cfg Config) (*settings, error) {
+	s := &settings{config: cfg}
+	var err error
+	if s.publicMatcher, err = newPathMatcher(cfg.PublicPaths); err != nil {
+		return nil, err
+	}
+	if s.optionalMatcher, err = newPathMatcher(cfg.OptionalPaths); err != nil {
+		return nil, err
+	}
+	if s.publicMethodMatcher, err = newPathMatcher(cfg.PublicMethods); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// fieldConfig returns the settings held by the fields of GToken
+func (m *GToken) fieldConfig() Config {
+	return Config{
+		SecretKey:        m.SecretKey,
+		Algorithm:        m.Algorithm,
+		ExpireIn:         m.ExpireIn,
+		TokenIDLength:    m.TokenIDLength,
+		SingleSession:    m.SingleSession,
+		AutoRefreshToken: m.AutoRefreshToken,
+		RejectInvalid:    m.RejectInvalid,
+		PublicPaths:      m.PublicPaths,
+		OptionalPaths:    m.OptionalPaths,
+		PublicMethods:    m.PublicMethods,
+		CacheMode:        m.CacheMode,
+		RedisGroup:       m.RedisGroup,
+	}
+}
+
+// settings returns the current snapshot. Before Init, it is read from the fields of GToken.
+func (m *GToken) settings() *settings {
+	if m.current != nil {
+		if s := m.current.Load(); s != nil {
+			return s
+		}
+	}
+	return &settings{config: m.fieldConfig()}
+}
+
+// storeSettings swaps the snapshot of GToken and its tenant views
+func (m *GToken) storeSettings(s *settings) {
+	if m.current == nil {
+		m.current = new(atomic.Pointer[settings])
+	}
+	m.current.Store(s)
+	for tenantID, view := range m.tenantViews {
+		view.storeSettings(m.tenantSettings(tenantID, s))
+	}
+}
+
+// tenantSettings returns the snapshot of a tenant view, which keeps the ExpireIn of its tenant
+func (m *GToken) tenantSettings(tenantID string, s *settings) *settings {
+	tenant := m.Tenants[tenantID]
+	if tenant == nil || tenant.ExpireIn <= 0 {
+		return s
+	}
+	viewSettings := *s
+	viewSettings.config.ExpireIn = tenant.ExpireIn
+	return &viewSettings
+}
+
+// CurrentConfig returns the settings in use, which may differ from the fields of GToken after Reload
+func (m *GToken) CurrentConfig() Config {
+	cfg := m.settings().config
+	cfg.SecretKey = bytes.Clone(cfg.SecretKey)
+	cfg.PublicPaths = slices.Clone(cfg.PublicPaths)
+	cfg.OptionalPaths = slices.Clone(cfg.OptionalPaths)
+	cfg.PublicMethods = slices.Clone(cfg.PublicMethods)
+	return cfg
+}
+
+// Reload applies cfg to GToken and its tenant views after Init. Requests in flight finish with the old settings,
+// and the following ones use the new settings. Empty values fall back to the defaults like Init.
+//
+// SecretKey, Algorithm, CacheMode and RedisGroup cannot be reloaded, since issued tokens depend on them.
+// A change of them, or an invalid path rule, is returned as an error and the old settings are kept.
+// The fields of GToken are not changed, use CurrentConfig to read the settings in use.
+func (m *GToken) Reload(ctx context.Context, cfg *Config) error {
+	if m.current == nil {
+		return errors.New(errorNotInitialized)
+	}
+	if m.tenantID != "" {
+		return fmt.Errorf("%s: %s", errorReloadTenantView, m.tenantID)
+	}
+	if cfg == nil {
+		return errors.New(errorInvalidConfig)
+	}
+	next := cfg.withDefaults()
+	old := m.settings().config
+	switch {
+	case !bytes.Equal(next.SecretKey, old.SecretKey):
+		return fmt.Errorf("%s: %s", errorReloadFixed, "SecretKey")
+	case next.Algorithm != old.Algorithm:
+		return fmt.Errorf("%s: %s", errorReloadFixed, "Algorithm")
+	case next.CacheMode != old.CacheMode:
+		return fmt.Errorf("%s: %s", errorReloadFixed, "CacheMode")
+	case next.RedisGroup != old.RedisGroup:
+		return fmt.Errorf("%s: %s", errorReloadFixed, "RedisGroup")
+	}
+	// keep the snapshot away from later changes of the caller
+	next.PublicPaths = slices.Clone(next.PublicPaths)
+	next.OptionalPaths = slices.Clone(next.OptionalPaths)
+	next.PublicMethods = slices.Clone(next.PublicMethods)
+	s, err := newSettings(next)
+	if err != nil {
+		return err
+	}
+	m.storeSettings(s)
+	m.log(ctx, LogLevelInfo, "config reloaded")
+	return nil
+}
+
+// WatchConfig reloads GToken by LoadConfig(pattern) whenever the config adapter of g.Cfg() reports a change,
+// e.g. a change of config.yaml with the default file adapter. It is called after Init.
+// SecretKey, Algorithm, CacheMode and RedisGroup left out of the config keep the ones in use, e.g. a SecretKey set in code,
+// so only the ones present in the config are compared by Reload.
+// A bad config is logged and the old settings are kept. The returned func stops watching.
+func (m *GToken) WatchConfig(ctx context.Context, pattern string) (stop func(), err error) {
+	if m.current == nil {
+		return nil, errors.New(errorNotInitialized)
+	}
+	watcher, ok := g.Cfg().GetAdapter().(gcfg.WatcherAdapter)
+	if !ok {
+		return nil, errors.New(errorConfigNotWatchable)
+	}
+	name := DefaultPrefixWatcher + pattern
+	watcher.AddWatcher(name, func(watchCtx context.Context) {
+		cfg, err1 := LoadConfig(watchCtx, pattern)
+		if err1 == nil {
+			cfg.keepFixed(m.settings().config)
+			err1 = m.Reload(watchCtx, cfg)
+		}
+		if err1 != nil {
+			m.log(watchCtx, LogLevelError, errorReloadConfig, "pattern", pattern, LogFieldError, err1)
+		}
+	})
+	m.log(ctx, LogLevelDebug, "watching config", "pattern", pattern)
+	return func() {
+		watcher.RemoveWatcher(name)
+	}, nil
+}
+
+// keepFixed fills the settings which cannot be reloaded with the ones in use, unless they are present in the config
+func (c *Config) keepFixed(current Config) {
+	if len(c.SecretKey) == 0 {
+		c.SecretKey = current.SecretKey
+	}
+	if c.Algorithm == "" {
+		c.Algorithm = current.Algorithm
+	}
+	if !c.storeMode {
+		c.CacheMode = current.CacheMode
+	}
+	if c.RedisGroup == "" {
+		c.RedisGroup = current.RedisGroup
+	}
+}
@@ -0,0 +1,152 @@
+package gtoken_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+func TestReload(t *testing.T) {
+	t.Log("test: reload settings at runtime")
+	ctx := context.Background()
+	gToken := &gtoken.GToken{PublicPaths: []string{"/public"}}
+	if err := gToken.Reload(ctx, &gtoken.Config{}); err == nil {
+		t.Error("error: Reload before Init should fail")
+	}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	handler := gToken.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	t.Log("1. new public paths and expiry apply to following requests")
+	if code := serve("/hello"); code != http.StatusUnauthorized {
+		t.Error("error: /hello should be protected, but:", code)
+	}
+	err := gToken.Reload(ctx, &gtoken.Config{PublicPaths: []string{"/public", "/hello"}, ExpireIn: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := serve("/hello"); code != http.StatusOK {
+		t.Error("error: /hello should be public, but:", code)
+	}
+	token, tokenInfo, err := gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := tokenInfo.ExpireAt.Sub(tokenInfo.RefreshAt); ttl < 29*time.Minute || ttl > 31*time.Minute {
+		t.Error("error: token should expire in 1 hour, but refresh window is:", ttl)
+	}
+	if _, err = gToken.RemoveToken(ctx, token); err != nil {
+		t.Error("error:", err)
+	}
+	if cfg := gToken.CurrentConfig(); cfg.ExpireIn != time.Hour || len(cfg.PublicPaths) != 2 || gToken.ExpireIn != gtoken.DefaultExpireIn {
+		t.Error("error: CurrentConfig is not correct:", cfg)
+	}
+
+	t.Log("2. fixed settings and bad rules are rejected, and the old settings are kept")
+	badConfigs := []*gtoken.Config{
+		{SecretKey: []byte("another-secret")},
+		{Algorithm: "HS512"},
+		{CacheMode: gtoken.CacheModeRedis},
+		{PublicPaths: []string{"~("}},
+	}
+	for _, cfg := range badConfigs {
+		if err = gToken.Reload(ctx, cfg); err == nil {
+			t.Errorf("error: config %v should be rejected", cfg)
+		}
+	}
+	if code := serve("/hello"); code != http.StatusOK {
+		t.Error("error: old settings should be kept, but:", code)
+	}
+
+	t.Log("3. tenant views keep their own expiry")
+	gToken = &gtoken.GToken{
+		Tenants:        map[string]*gtoken.Tenant{"acme": {ExpireIn: 10 * time.Minute}, "globex": nil},
+		TenantResolver: gtoken.TenantFromHeader("X-Tenant-ID"),
+	}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	if err = gToken.Reload(ctx, &gtoken.Config{ExpireIn: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	acme, _ := gToken.ForTenant("acme")
+	globex, _ := gToken.ForTenant("globex")
+	if acme.CurrentConfig().ExpireIn != 10*time.Minute || globex.CurrentConfig().ExpireIn != time.Hour {
+		t.Error("error: expiry of tenants is not correct:", acme.CurrentConfig().ExpireIn, globex.CurrentConfig().ExpireIn)
+	}
+	if err = acme.Reload(ctx, &gtoken.Config{}); err == nil {
+		t.Error("error: a tenant view should not be reloaded")
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	t.Log("test: reload by config changes")
+	ctx := context.Background()
+	adapter := useConfig(t, "auth:\n  expireIn: 2h\n")
+	gToken, err := gtoken.NewFromConfig(ctx, "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// settings which cannot be reloaded may be set in code instead of the config
+	gToken.SecretKey = []byte("secret-in-code")
+	gToken.Algorithm = "HS512"
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	stop, err := gToken.WatchConfig(ctx, "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	// watchers are called in goroutines
+	waitExpireIn := func(expected time.Duration) bool {
+		for i := 0; i < 100; i++ {
+			if gToken.CurrentConfig().ExpireIn == expected {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	t.Log("1. a change of the config is applied, and settings left out of it are kept")
+	if err = adapter.SetContent("auth:\n  expireIn: 3h\n  publicPaths: [\"/hello\"]\n"); err != nil {
+		t.Fatal(err)
+	}
+	if !waitExpireIn(3 * time.Hour) {
+		t.Error("error: config is not reloaded:", gToken.CurrentConfig())
+	}
+	if cfg := gToken.CurrentConfig(); string(cfg.SecretKey) != "secret-in-code" || cfg.Algorithm != "HS512" {
+		t.Error("error: settings set in code should be kept:", cfg)
+	}
+
+	t.Log("2. a bad config is ignored")
+	if err = adapter.SetContent("auth:\n  expireIn: 4h\n  secretKey: another-secret\n"); err != nil {
+		t.Fatal(err)
+	}
+	if waitExpireIn(4 * time.Hour) {
+		t.Error("error: a bad config should not be applied")
+	}
+
+	t.Log("3. no reload after stop")
+	stop()
+	if err = adapter.SetContent("auth:\n  expireIn: 5h\n"); err != nil {
+		t.Fatal(err)
+	}
+	if waitExpireIn(5 * time.Hour) {
+		t.Error("error: config is reloaded after stop")
+	}
+}
@@ -0,0 +1,75 @@
+package gtoken
+
+import (
+	"context"
+	"net/http"
+)
+
+// RevocationHandler returns an OAuth 2.0 Token Revocation endpoint (RFC 7009), a ready-made logout for any client.
+// A client posts "token" and an optional "token_type_hint" as a form. Access and refresh tokens are the same in GToken,
+// so the hint is accepted but not needed. The response is 200 whether the token is revoked, invalid or unknown,
+// so tokens cannot be probed by it. Tenants are resolved by TenantResolver.
+//
+// If clients is nil, anyone holding a token may revoke it, like the logout of a browser app, so the path should be public.
+// Otherwise, the client is authenticated like IntrospectionHandler, and only revokes tokens issued to it by WithClientID,
+// see RFC 7009 section 2.1. Tokens without a client id are kept as well.
+//
+//	group.POST("/oauth/revoke", ghttp.WrapH(gToken.RevocationHandler(nil)))
+func (m *GToken) RevocationHandler(clients ClientCredentials) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeOAuthError(w, http.StatusMethodNotAllowed, OAuthErrorInvalidRequest)
+			return
+		}
+		var clientID string
+		if clients != nil {
+			var ok bool
+			if clientID, ok = clients.authenticate(r); !ok {
+				w.Header().Set("WWW-Authenticate", `Basic realm="gtoken"`)
+				writeOAuthError(w, http.StatusUnauthorized, OAuthErrorInvalidClient)
+				return
+			}
+		}
+		token := r.PostFormValue("token")
+		if token == "" {
+			writeOAuthError(w, http.StatusBadRequest, OAuthErrorInvalidRequest)
+			return
+		}
+		// a token of an unknown tenant is invalid, and answered like a revoked one
+		if gt, err := m.ForRequest(r); err == nil {
+			if err = gt.revokeToken(r.Context(), token, clientID); err != nil {
+				// the token may still be valid, so the client should retry, see RFC 7009 section 2.2.1
+				m.log(r.Context(), LogLevelError, errorRevokeToken, LogFieldError, err)
+				w.Header().Set("Retry-After", "1")
+				writeOAuthError(w, http.StatusServiceUnavailable, OAuthErrorUnavailable)
+				return
+			}
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// revokeToken removes a token unless it is invalid, unknown or not issued to the authenticated client. Only store errors are returned.
+func (m *GToken) revokeToken(ctx context.Context, token string, clientID string) error {
+	if _, err := m.decryptJWT(token); err != nil {
+		return nil
+	}
+	tokenInfo, err := m.getTokenCache(ctx, token)
+	if err != nil {
+		if err.Error() == errorTokenNotFound {
+			return nil
+		}
+		return err
+	}
+	// an authenticated client only revokes its own tokens, which excludes tokens of no client
+	if clientID != "" && tokenInfo.ClientID != clientID {
+		return nil
+	}
+	// a token removed in the meantime is revoked as well
+	if _, err = m.RemoveToken(ctx, token); err != nil && err.Error() != errorTokenNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package gtoken_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+func TestRevocationHandler(t *testing.T) {
+	t.Log("test: token revocation endpoint")
+	ctx := context.Background()
+	gToken := &gtoken.GToken{}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+
+	s := g.Server("revocation")
+	s.SetPort(8088)
+	s.Group("/oauth", func(group *ghttp.RouterGroup) {
+		group.POST("/revoke", ghttp.WrapH(gToken.RevocationHandler(nil)))
+		group.ALL("/client/revoke", ghttp.WrapH(gToken.RevocationHandler(gtoken.ClientCredentials{
+			"web":    "web-secret",
+			"orders": "orders-secret",
+		})))
+	})
+	err := s.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Shutdown()
+	}()
+
+	revoke := func(method string, path string, form url.Values, basicAuth ...string) int {
+		client := g.Client()
+		client.SetPrefix("http://127.0.0.1:8088")
+		client.SetHeader("Content-Type", "application/x-www-form-urlencoded")
+		if len(basicAuth) == 2 {
+			client.SetBasicAuth(basicAuth[0], basicAuth[1])
+		}
+		res, err1 := client.DoRequest(ctx, method, path, form.Encode())
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		defer func() {
+			_ = res.Close()
+		}()
+		return res.StatusCode
+	}
+	newToken := func(opts ...gtoken.TokenOption) string {
+		token, _, err1 := gToken.NewToken(ctx, userId, nil, opts...)
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		return token
+	}
+	valid := func(token string) bool {
+		_, err1 := gToken.ValidateToken(ctx, token)
+		return err1 == nil
+	}
+
+	t.Log("1. a token is revoked with any hint")
+	token := newToken()
+	if code := revoke(http.MethodPost, "/oauth/revoke", url.Values{"token": {token}, "token_type_hint": {"refresh_token"}}); code != http.StatusOK {
+		t.Error("error: code should be 200, but:", code)
+	}
+	if valid(token) {
+		t.Error("error: token should be revoked")
+	}
+
+	t.Log("2. revoked, unknown and invalid tokens are answered the same")
+	for _, invalid := range []string{token, "invalid"} {
+		if code := revoke(http.MethodPost, "/oauth/revoke", url.Values{"token": {invalid}}); code != http.StatusOK {
+			t.Error("error: code should be 200, but:", code)
+		}
+	}
+	if code := revoke(http.MethodPost, "/oauth/revoke", url.Values{}); code != http.StatusBadRequest {
+		t.Error("error: request without token should be 400, but:", code)
+	}
+
+	t.Log("3. clients are authenticated, and only revoke their own tokens")
+	token = newToken(gtoken.WithClientID("web"))
+	if code := revoke(http.MethodPost, "/oauth/client/revoke", url.Values{"token": {token}}); code != http.StatusUnauthorized {
+		t.Error("error: request without credentials should be 401, but:", code)
+	}
+	if code := revoke(http.MethodGet, "/oauth/client/revoke", url.Values{"token": {token}}, "web", "web-secret"); code != http.StatusMethodNotAllowed {
+		t.Error("error: GET should be 405, but:", code)
+	}
+	if code := revoke(http.MethodPost, "/oauth/client/revoke", url.Values{"token": {token}}, "orders", "orders-secret"); code != http.StatusOK {
+		t.Error("error: code should be 200, but:", code)
+	}
+	if !valid(token) {
+		t.Error("error: token of another client should be kept")
+	}
+	form := url.Values{"token": {token}, "client_id": {"web"}, "client_secret": {"web-secret"}}
+	if code := revoke(http.MethodPost, "/oauth/client/revoke", form); code != http.StatusOK {
+		t.Error("error: code should be 200, but:", code)
+	}
+	if valid(token) {
+		t.Error("error: token should be revoked by its client")
+	}
+
+	t.Log("4. clients cannot revoke tokens of no client")
+	token = newToken()
+	if code := revoke(http.MethodPost, "/oauth/client/revoke", url.Values{"token": {token}}, "web", "web-secret"); code != http.StatusOK {
+		t.Error("error: code should be 200, but:", code)
+	}
+	if !valid(token) {
+		t.Error("error: token without a client id should be kept")
+	}
+}
@@ -0,0 +1,97 @@
+package gtoken
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+// WithScopes sets what a new token may do, e.g. an integration token with "orders:read" only.
+// Scopes are matched the same way as RBAC permissions, so "orders:*" satisfies "orders:read".
+// A token without scopes, e.g. the login of a user, is not restricted by scopes and satisfies every route.
+func WithScopes(scopes ...string) TokenOption {
+	return func(tokenInfo *TokenInfo) {
+		tokenInfo.Scopes = scopes
+	}
+}
+
+type scopeRule struct {
+	matcher *pathMatcher
+	scopes  []string
+}
+
+// newScopeRules compiles ScopeRules, each key is a path rule in the same formats as PublicPaths
+func newScopeRules(rules map[string][]string) ([]scopeRule, error) {
+	compiled := make([]scopeRule, 0, len(rules))
+	for pattern, scopes := range rules {
+		matcher, err := newPathMatcher([]string{pattern})
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, scopeRule{matcher: matcher, scopes: scopes})
+	}
+	return compiled, nil
+}
+
+// parseScopes splits scopes separated by spaces (RFC 6749) or commas
+func parseScopes(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+}
+
+// requiredScopes returns scopes declared in g.Meta of the matched handler and all matched ScopeRules
+func (m *GToken) requiredScopes(r *ghttp.Request) []string {
+	scopes := parseScopes(r.GetServeHandler().GetMetaTag(MetaTagScopes))
+	return append(scopes, m.ruleScopes(r.URL.Path, r.Method)...)
+}
+
+// ruleScopes returns scopes of all matched ScopeRules
+func (m *GToken) ruleScopes(urlPath string, urlMethod string) []string {
+	var scopes []string
+	for _, rule := range m.scopeRules {
+		if rule.matcher.matches(urlPath, urlMethod) {
+			scopes = append(scopes, rule.scopes...)
+		}
+	}
+	return scopes
+}
+
+// HasScopes reports whether the token info is granted all the scopes.
+// Scopes only restrict tokens issued with them, so a token without scopes has any scope.
+func (t *TokenInfo) HasScopes(scopes ...string) bool {
+	if len(t.Scopes) == 0 {
+		return true
+	}
+	for _, required := range scopes {
+		granted := false
+		for _, item := range t.Scopes {
+			if matchPermission(item, required) {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			return false
+		}
+	}
+	return true
+}
+
+// checkScopes writes 403 with insufficient_scope by DoForbidden if the session lacks required scopes
+func (m *GToken) checkScopes(r *ghttp.Request, tokenInfo *TokenInfo) bool {
+	required := m.requiredScopes(r)
+	if tokenInfo.HasScopes(required...) {
+		return true
+	}
+	r.Response.Header().Set("WWW-Authenticate", insufficientScopeChallenge(required))
+	m.DoForbidden(r, g.Map{"reason": ReasonInsufficientScope, "required": required})
+	return false
+}
+
+// insufficientScopeChallenge returns the WWW-Authenticate header value defined by RFC 6750
+func insufficientScopeChallenge(required []string) string {
+	return fmt.Sprintf(`Bearer error="%s", scope="%s"`, ReasonInsufficientScope, strings.Join(required, " "))
+}
@@ -0,0 +1,150 @@
+package gtoken
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestScopeClaim(t *testing.T) {
+	t.Log("test: scopes in jwt claim")
+	ctx := context.Background()
+	gToken := &GToken{ScopeClaim: true, SingleSession: true}
+	gToken.Init(ctx)
+
+	t.Log("1. the scope claim is embedded")
+	token, tokenInfo, err := gToken.NewToken(ctx, "scope-user", nil, WithScopes("orders:read", "orders:write"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := &tokenClaims{}
+	if _, err = jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return gToken.SecretKey, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Scope != "orders:read orders:write" || claims.ID != tokenInfo.TokenID {
+		t.Error("error: scope claim is not correct", claims)
+	}
+
+	t.Log("2. the token with scope claim can be validated")
+	validatedInfo, err := gToken.ValidateToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !validatedInfo.HasScopes("orders:read") || validatedInfo.HasScopes("users:read") {
+		t.Error("error: scopes are not correct", validatedInfo.Scopes)
+	}
+
+	t.Log("3. single session still removes the old token with scope claim")
+	_, _, err = gToken.NewToken(ctx, "scope-user", nil, WithScopes("orders:read"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = gToken.ValidateToken(ctx, token); err == nil {
+		t.Error("error: the old token is not removed")
+	}
+}
+
+type scopeMetaReq struct {
+	g.Meta `path:"/scope/users" method:"get" scopes:"users:read"`
+}
+
+type scopeController struct{}
+
+func (c *scopeController) Users(ctx context.Context, req *scopeMetaReq) (res *DefaultResponse, err error) {
+	return
+}
+
+func TestScopeRules(t *testing.T) {
+	t.Log("test: scope enforcement")
+	ctx := context.Background()
+	gToken := &GToken{ScopeRules: map[string][]string{"POST:/scope/orders": {"orders:write"}}}
+
+	s := g.Server("scope")
+	s.SetPort(8084)
+	s.Group("/", func(group *ghttp.RouterGroup) {
+		group.Middleware(ghttp.MiddlewareHandlerResponse)
+		err := gToken.UseMiddleware(ctx, group)
+		if err != nil {
+			t.Fatal(err)
+		}
+		group.ALL("/scope/orders", func(r *ghttp.Request) {
+			r.Response.WriteJson(DefaultResponse{Msg: "ok"})
+		})
+		group.Bind(&scopeController{})
+	})
+	err := s.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Shutdown()
+	}()
+
+	request := func(method string, path string, token string) (*DefaultResponse, string) {
+		client := g.Client()
+		client.SetHeader("Authorization", PrefixBearer+token)
+		resp, err1 := client.DoRequest(ctx, method, "http://127.0.0.1:8084"+path)
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		defer resp.Close()
+		res := DefaultResponse{}
+		if err1 = gjson.DecodeTo(resp.ReadAll(), &res); err1 != nil {
+			t.Error("error:", err1)
+		}
+		return &res, resp.Header.Get("WWW-Authenticate")
+	}
+
+	readToken, _, err := gToken.NewToken(ctx, "integration", nil, WithScopes("orders:read", "users:read"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeToken, _, err := gToken.NewToken(ctx, "integration", nil, WithScopes("orders:*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("1. a route without required scopes is allowed")
+	if res, _ := request(http.MethodGet, "/scope/orders", readToken); res.Code != DefaultCodeOK {
+		t.Error("error:", res)
+	}
+	t.Log("2. lacking a scope of ScopeRules gets 403 with insufficient_scope")
+	res, header := request(http.MethodPost, "/scope/orders", readToken)
+	if res.Code != DefaultCodeForbidden || gjson.New(res.Data).Get("reason").String() != ReasonInsufficientScope {
+		t.Error("error:", res)
+	}
+	if header != `Bearer error="insufficient_scope", scope="orders:write"` {
+		t.Error("error: WWW-Authenticate header is not correct:", header)
+	}
+	t.Log("3. a wildcard scope satisfies ScopeRules")
+	if res, _ = request(http.MethodPost, "/scope/orders", writeToken); res.Code != DefaultCodeOK {
+		t.Error("error:", res)
+	}
+	t.Log("4. scopes in g.Meta are enforced")
+	if res, _ = request(http.MethodGet, "/scope/users", readToken); res.Code != DefaultCodeOK {
+		t.Error("error:", res)
+	}
+	if res, _ = request(http.MethodGet, "/scope/users", writeToken); res.Code != DefaultCodeForbidden {
+		t.Error("error:", res)
+	}
+	t.Log("5. a token without scopes, e.g. a user login, is not restricted")
+	userToken, _, err := gToken.NewToken(ctx, "scope-login", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if res, _ = request(method, "/scope/orders", userToken); res.Code != DefaultCodeOK {
+			t.Error("error:", res)
+		}
+	}
+	if res, _ = request(http.MethodGet, "/scope/users", userToken); res.Code != DefaultCodeOK {
+		t.Error("error:", res)
+	}
+}
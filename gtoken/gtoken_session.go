@@ -0,0 +1,208 @@
+package gtoken
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gcache"
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// Session returns a live session by its token id. An unknown id is an error of gcode.CodeNotFound.
+func (m *GToken) Session(ctx context.Context, tokenID string) (*TokenInfo, error) {
+	token, err := m.encryptJWT(tokenID)
+	if err != nil {
+		return nil, err
+	}
+	tokenInfo, err := m.getTokenCache(ctx, token)
+	if err != nil {
+		if err.Error() == errorTokenNotFound {
+			return nil, gerror.NewCode(gcode.CodeNotFound, errorTokenNotFound)
+		}
+		return nil, err
+	}
+	return tokenInfo, nil
+}
+
+// UserSessions returns live sessions of a user, sorted by expiry
+func (m *GToken) UserSessions(ctx context.Context, userID string) ([]*TokenInfo, error) {
+	ids, err := m.userTokenIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tokenInfos := make([]*TokenInfo, 0, len(ids))
+	for _, id := range ids {
+		tokenInfo, err1 := m.Session(ctx, id)
+		if err1 != nil {
+			if gerror.Code(err1) == gcode.CodeNotFound {
+				// expired, but not pruned from the index yet
+				continue
+			}
+			return nil, err1
+		}
+		tokenInfos = append(tokenInfos, tokenInfo)
+	}
+	sortSessions(tokenInfos)
+	return tokenInfos, nil
+}
+
+// RevokeSession removes a session by its token id, and fires OnRevoke with reason admin_revoked.
+// An unknown id is an error of gcode.CodeNotFound.
+func (m *GToken) RevokeSession(ctx context.Context, tokenID string) error {
+	tokenInfo, err := m.Session(ctx, tokenID)
+	if err != nil {
+		return err
+	}
+	token, err := m.encryptJWT(tokenID)
+	if err != nil {
+		return err
+	}
+	ok, err := m.removeTokenCache(ctx, token)
+	if err != nil {
+		return err
+	}
+	if ok {
+		m.revoked(ctx, ReasonAdminRevoked, tokenInfo)
+	}
+	return nil
+}
+
+// RevokeUserSessions removes all sessions of a user, fires OnRevoke with reason admin_revoked, and returns the number of them
+func (m *GToken) RevokeUserSessions(ctx context.Context, userID string) (revoked int, err error) {
+	tokenInfos, ok, err := m.removeUserCache(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		m.revoked(ctx, ReasonAdminRevoked, tokenInfos...)
+	}
+	return len(tokenInfos), nil
+}
+
+// ExportSessions returns live sessions of GToken and its tenant views, e.g. to move them to another store by ImportSessions
+func (m *GToken) ExportSessions(ctx context.Context) ([]*TokenInfo, error) {
+	tenantIDs := make([]string, 0, len(m.tenantViews))
+	for tenantID := range m.tenantViews {
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+	sort.Strings(tenantIDs)
+	views := []*GToken{m}
+	for _, tenantID := range tenantIDs {
+		views = append(views, m.tenantViews[tenantID])
+	}
+	tokenInfos := make([]*TokenInfo, 0)
+	for _, gt := range views {
+		sessions, err := gt.sessions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tokenInfos = append(tokenInfos, sessions...)
+	}
+	return tokenInfos, nil
+}
+
+// ImportSessions stores sessions returned by ExportSessions until their original expiry, so their tokens keep working.
+// Expired sessions are skipped. A session of a tenant is stored by its tenant view, which should have the same secret as before.
+func (m *GToken) ImportSessions(ctx context.Context, tokenInfos []*TokenInfo) (imported int, err error) {
+	defer func() {
+		// keep file content up-to-date, even if a session fails
+		if imported > 0 && m.CacheMode == CacheModeFile {
+			m.saveToFile(ctx)
+		}
+	}()
+	for _, tokenInfo := range tokenInfos {
+		if tokenInfo == nil || tokenInfo.UserID == "" || tokenInfo.TokenID == "" {
+			return imported, errors.New(errorInvalidSession)
+		}
+		if tokenInfo.ExpireAt == nil {
+			continue
+		}
+		ttl := tokenInfo.ExpireAt.Sub(gtime.Now())
+		if ttl <= 0 {
+			continue
+		}
+		gt := m
+		if tokenInfo.TenantID != "" {
+			if gt, err = m.ForTenant(tokenInfo.TenantID); err != nil {
+				return imported, err
+			}
+		}
+		token, err1 := gt.encryptJWT(tokenInfo.TokenID)
+		if err1 != nil {
+			return imported, err1
+		}
+		if _, err = gt.putTokenCache(ctx, token, tokenInfo, ttl); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+// sessions returns live sessions in the key namespace of GToken, other tenants are left to their views
+func (m *GToken) sessions(ctx context.Context) ([]*TokenInfo, error) {
+	tokens, err := m.tokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tokenInfos := make([]*TokenInfo, 0, len(tokens))
+	for _, token := range tokens {
+		// a token signed by another secret, e.g. left by a former config, cannot be rebuilt from its id
+		if _, err = m.decryptJWT(token); err != nil {
+			continue
+		}
+		tokenInfo, err1 := m.getTokenCache(ctx, token)
+		if err1 != nil {
+			if err1.Error() == errorTokenNotFound {
+				continue
+			}
+			return nil, err1
+		}
+		tokenInfos = append(tokenInfos, tokenInfo)
+	}
+	sortSessions(tokenInfos)
+	return tokenInfos, nil
+}
+
+// tokens returns tokens stored in the key namespace of GToken
+func (m *GToken) tokens(ctx context.Context) ([]string, error) {
+	prefix := m.tokenKey("")
+	var tokens []string
+	switch m.CacheMode {
+	case CacheModeCache, CacheModeFile:
+		keys, err := gcache.KeyStrings(ctx)
+		if err != nil {
+			m.log(ctx, LogLevelError, errorGetCache, LogFieldError, err)
+			return nil, err
+		}
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				tokens = append(tokens, key[len(prefix):])
+			}
+		}
+	case CacheModeRedis:
+		err := m.scanRedisKeys(ctx, escapeRedisGlob(prefix)+"*", func(keys []string) error {
+			for _, key := range keys {
+				tokens = append(tokens, key[len(prefix):])
+			}
+			return nil
+		})
+		if err != nil {
+			m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err)
+			return nil, err
+		}
+	default:
+		return nil, errors.New(errorInvalidMode)
+	}
+	return tokens, nil
+}
+
+func sortSessions(tokenInfos []*TokenInfo) {
+	sort.Slice(tokenInfos, func(i, j int) bool {
+		return tokenInfos[i].ExpireAt.Before(tokenInfos[j].ExpireAt)
+	})
+}
@@ -0,0 +1,148 @@
+package gtoken_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/gogf/gf/v2/errors/gcode"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gcache"
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+func TestSessions(t *testing.T) {
+	t.Log("test: manage sessions by token id and user id")
+	ctx := context.Background()
+	var revokeReasons []string
+	gToken := &gtoken.GToken{
+		Tenants:        map[string]*gtoken.Tenant{"acme": {SecretKey: []byte("acme-secret")}},
+		TenantResolver: gtoken.TenantFromHeader("X-Tenant-ID"),
+		OnRevoke: []gtoken.TokenListener{func(ctx context.Context, event gtoken.TokenEvent) {
+			revokeReasons = append(revokeReasons, event.Reason)
+		}},
+	}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	acme, err := gToken.ForTenant("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newToken := func(gt *gtoken.GToken, userID string, opts ...gtoken.TokenOption) (string, *gtoken.TokenInfo) {
+		token, tokenInfo, err1 := gt.NewToken(ctx, userID, nil, opts...)
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		return token, tokenInfo
+	}
+	// exported returns the exported sessions of a user
+	exported := func(userID string) []*gtoken.TokenInfo {
+		tokenInfos, err1 := gToken.ExportSessions(ctx)
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		var sessions []*gtoken.TokenInfo
+		for _, tokenInfo := range tokenInfos {
+			if tokenInfo.UserID == userID {
+				sessions = append(sessions, tokenInfo)
+			}
+		}
+		return sessions
+	}
+	token1, tokenInfo1 := newToken(gToken, "session-user", gtoken.WithScopes("orders:read"))
+	_, tokenInfo2 := newToken(gToken, "session-user")
+	acmeToken, acmeTokenInfo := newToken(acme, "session-user")
+
+	t.Log("1. sessions are got by token id and user id")
+	if tokenInfo, err1 := gToken.Session(ctx, tokenInfo1.TokenID); err1 != nil || tokenInfo.UserID != "session-user" {
+		t.Error("error: session should be found:", err1)
+	}
+	if _, err = gToken.Session(ctx, "unknown"); gerror.Code(err) != gcode.CodeNotFound {
+		t.Error("error: unknown session should be CodeNotFound, but:", err)
+	}
+	tokenInfos, err := gToken.UserSessions(ctx, "session-user")
+	if err != nil || len(tokenInfos) != 2 || tokenInfos[0].TokenID != tokenInfo1.TokenID {
+		t.Error("error: sessions should be sorted by expiry:", tokenInfos, err)
+	}
+
+	t.Log("2. sessions of all tenants are exported, and imported until their original expiry")
+	sessions := exported("session-user")
+	if len(sessions) != 3 || sessions[2].TenantID != "acme" {
+		t.Fatal("error: 3 sessions should be exported, but:", sessions)
+	}
+	if _, err = gToken.RemoveUserTokens(ctx, "session-user"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = acme.RemoveUserTokens(ctx, "session-user"); err != nil {
+		t.Fatal(err)
+	}
+	expired := *tokenInfo2
+	expired.TokenID = "expired-id"
+	expired.ExpireAt = expired.ExpireAt.Add(-2 * gtoken.DefaultExpireIn)
+	imported, err := gToken.ImportSessions(ctx, append(sessions, &expired))
+	if err != nil || imported != 3 {
+		t.Fatal("error: 3 sessions should be imported, but:", imported, err)
+	}
+	if tokenInfo, err1 := gToken.ValidateToken(ctx, token1); err1 != nil || !tokenInfo.ExpireAt.Equal(tokenInfo1.ExpireAt) {
+		t.Error("error: imported token should be valid until its original expiry:", err1)
+	}
+	if _, err = acme.ValidateToken(ctx, acmeToken); err != nil {
+		t.Error("error: imported token of tenant should be valid:", err)
+	}
+	if _, err = gToken.ImportSessions(ctx, []*gtoken.TokenInfo{{TokenID: "no-user"}}); err == nil {
+		t.Error("error: session without user id should be rejected")
+	}
+
+	t.Log("3. sessions are revoked by token id and user id")
+	if err = gToken.RevokeSession(ctx, tokenInfo1.TokenID); err != nil {
+		t.Error("error:", err)
+	}
+	if _, err = gToken.ValidateToken(ctx, token1); err == nil {
+		t.Error("error: token should be revoked")
+	}
+	if err = gToken.RevokeSession(ctx, tokenInfo1.TokenID); gerror.Code(err) != gcode.CodeNotFound {
+		t.Error("error: revoked session should be CodeNotFound, but:", err)
+	}
+	if revoked, err1 := acme.RevokeUserSessions(ctx, "session-user"); err1 != nil || revoked != 1 {
+		t.Error("error: 1 session should be revoked, but:", revoked, err1)
+	}
+	if _, err = acme.Session(ctx, acmeTokenInfo.TokenID); err == nil {
+		t.Error("error: session of tenant should be revoked")
+	}
+	if _, err = gToken.RevokeUserSessions(ctx, "session-user"); err != nil {
+		t.Error("error:", err)
+	}
+	// the first 3 are revoked by RemoveUserTokens before importing
+	if expected := []string{gtoken.ReasonAdminRevoked, gtoken.ReasonAdminRevoked, gtoken.ReasonAdminRevoked}; len(revokeReasons) != 6 || !slices.Equal(revokeReasons[3:], expected) {
+		t.Error("error: revocations should fire OnRevoke with reason admin_revoked, but:", revokeReasons)
+	}
+}
+
+func TestFileModeUserIndex(t *testing.T) {
+	t.Log("test: user indexes of file mode are rebuilt from the file")
+	ctx := context.Background()
+	gToken := &gtoken.GToken{CacheMode: gtoken.CacheModeFile}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	_, tokenInfo, err := gToken.NewToken(ctx, "file-user", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = gToken.RemoveUserTokens(ctx, "file-user")
+	}()
+	// a restart only keeps what the file has
+	if _, err = gcache.Remove(ctx, "user:file-user"); err != nil {
+		t.Fatal(err)
+	}
+	restarted := &gtoken.GToken{CacheMode: gtoken.CacheModeFile}
+	if !restarted.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	tokenInfos, err := restarted.UserSessions(ctx, "file-user")
+	if err != nil || len(tokenInfos) != 1 || tokenInfos[0].TokenID != tokenInfo.TokenID {
+		t.Error("error: user index should be rebuilt, but:", tokenInfos, err)
+	}
+}
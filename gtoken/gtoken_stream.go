@@ -0,0 +1,146 @@
+package gtoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// A streaming response like Server-Sent Events or long-polling is authenticated once when it starts,
+// but it may outlive ExpireAt. WatchStream re-validates the token during the stream:
+//
+//	s.BindHandler("/events", func(r *ghttp.Request) {
+//	    ctx, stop := gToken.WatchStream(r, 0)
+//	    defer stop()
+//	    r.Response.Header().Set("Content-Type", "text/event-stream")
+//	    for {
+//	        select {
+//	        case <-ctx.Done():
+//	            if reason := gtoken.StreamEndReason(ctx); reason != "" {
+//	                gtoken.WriteSSEReauth(r.Response, reason)
+//	            }
+//	            return
+//	        case msg := <-messages:
+//	            r.Response.Writef("data: %s\n\n", msg)
+//	            r.Response.Flush()
+//	        }
+//	    }
+//	})
+
+// streamEndError is the cause of a watched context canceled by the token
+type streamEndError struct {
+	reason string
+}
+
+func (e *streamEndError) Error() string {
+	return e.reason
+}
+
+// StreamEndReason returns ReasonTokenExpired or ReasonTokenRevoked if the context of WatchStream or WatchToken
+// is canceled by the token, or "" if it is canceled otherwise, e.g. the client is gone.
+func StreamEndReason(ctx context.Context) string {
+	var e *streamEndError
+	if errors.As(context.Cause(ctx), &e) {
+		return e.reason
+	}
+	return ""
+}
+
+// WatchStream is WatchToken for the token of an authenticated request.
+// For an anonymous request on optional paths, the context is only canceled with the request.
+func (m *GToken) WatchStream(r *ghttp.Request, interval time.Duration) (context.Context, context.CancelFunc) {
+	token := ParseRequestToken(r)
+	if _, ok := FromContext(r.Context()); !ok || token == "" {
+		return context.WithCancel(r.Context())
+	}
+	gt, err := m.ForRequest(r.Request)
+	if err != nil {
+		return context.WithCancel(r.Context())
+	}
+	return gt.WatchToken(r.Context(), token, interval)
+}
+
+// WatchToken returns a context which is canceled when the token expires or is revoked.
+// The token is re-validated every interval (DefaultStreamCheckInterval if 0) and at its ExpireAt,
+// without refreshing it as a normal request does. Errors of the store do not end the stream, it is checked again later.
+// Use StreamEndReason to tell why the context is canceled.
+// Call the returned func to stop watching.
+func (m *GToken) WatchToken(ctx context.Context, token string, interval time.Duration) (context.Context, context.CancelFunc) {
+	if interval <= 0 {
+		interval = DefaultStreamCheckInterval
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		var expireAt *gtime.Time
+		for {
+			reason, nextExpireAt := m.checkStreamToken(ctx, token, expireAt)
+			if reason != "" {
+				cancel(&streamEndError{reason: reason})
+				return
+			}
+			expireAt = nextExpireAt
+			wait := interval
+			// ExpireAt is unknown if the store failed at the first check
+			if expireAt != nil {
+				if untilExpire := expireAt.Sub(gtime.Now()); untilExpire < wait {
+					wait = untilExpire
+				}
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+	return ctx, func() {
+		cancel(context.Canceled)
+	}
+}
+
+// checkStreamToken returns a reason if the token is no longer valid, or its latest ExpireAt.
+// A token missing before its last known ExpireAt, or of another tenant, is seen as revoked.
+// Other errors of the store, e.g. a redis timeout, keep the last known ExpireAt, so the next check tries again.
+func (m *GToken) checkStreamToken(ctx context.Context, token string, expireAt *gtime.Time) (reason string, nextExpireAt *gtime.Time) {
+	tokenInfo, err := m.getTokenCache(ctx, token)
+	if err == nil {
+		err = m.checkTenant(tokenInfo)
+	}
+	if err != nil {
+		if expireAt != nil && !gtime.Now().Before(expireAt) {
+			return ReasonTokenExpired, nil
+		}
+		switch err.Error() {
+		case errorTokenNotFound, errorTenantMismatch:
+			return ReasonTokenRevoked, nil
+		}
+		return "", expireAt
+	}
+	if !gtime.Now().Before(tokenInfo.ExpireAt) {
+		return ReasonTokenExpired, nil
+	}
+	return "", tokenInfo.ExpireAt
+}
+
+// WriteSSEReauth writes a "reauth" event with DefaultResponse as data and flushes it,
+// so the client can get a new token and reconnect. *ghttp.Response can be used as w.
+func WriteSSEReauth(w http.ResponseWriter, reason string) {
+	data := gjson.MustEncode(DefaultResponse{
+		Code: DefaultCodeUnauthorized,
+		Msg:  errorUnauthorized,
+		Data: g.Map{"reason": reason},
+	})
+	_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", SSEEventReauth, data)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
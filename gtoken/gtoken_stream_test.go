@@ -0,0 +1,93 @@
+package gtoken_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+func TestWatchToken(t *testing.T) {
+	t.Log("test: re-validate tokens of streams")
+	ctx := context.Background()
+	gToken := &gtoken.GToken{ExpireIn: 300 * time.Millisecond}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	waitEnd := func(streamCtx context.Context) string {
+		select {
+		case <-streamCtx.Done():
+			return gtoken.StreamEndReason(streamCtx)
+		case <-time.After(2 * time.Second):
+			t.Error("error: stream is not ended")
+			return ""
+		}
+	}
+
+	t.Log("1. stream ends when the token expires")
+	token, _, err := gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamCtx, stop := gToken.WatchToken(ctx, token, time.Minute)
+	if reason := waitEnd(streamCtx); reason != gtoken.ReasonTokenExpired {
+		t.Error("error: reason should be token_expired, but:", reason)
+	}
+	stop()
+
+	t.Log("2. stream ends when the token is revoked")
+	gToken.ExpireIn = time.Minute
+	token, _, err = gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamCtx, stop = gToken.WatchToken(ctx, token, 50*time.Millisecond)
+	defer stop()
+	if _, err = gToken.RemoveToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if reason := waitEnd(streamCtx); reason != gtoken.ReasonTokenRevoked {
+		t.Error("error: reason should be token_revoked, but:", reason)
+	}
+
+	t.Log("3. stopped stream has no reason")
+	token, _, err = gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamCtx, stop = gToken.WatchToken(ctx, token, 50*time.Millisecond)
+	stop()
+	if reason := waitEnd(streamCtx); reason != "" {
+		t.Error("error: reason should be empty, but:", reason)
+	}
+
+	t.Log("4. errors of the store do not end the stream")
+	// nothing listens on port 1, so every check fails
+	redis, err := gredis.New(&gredis.Config{Address: "127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	downToken := &gtoken.GToken{CacheMode: gtoken.CacheModeRedis, Redis: redis}
+	if !downToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	streamCtx, stop = downToken.WatchToken(ctx, token, 50*time.Millisecond)
+	defer stop()
+	select {
+	case <-streamCtx.Done():
+		t.Error("error: stream should be kept, but:", gtoken.StreamEndReason(streamCtx))
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	t.Log("5. reauth event")
+	w := httptest.NewRecorder()
+	gtoken.WriteSSEReauth(w, gtoken.ReasonTokenExpired)
+	body := w.Body.String()
+	if !strings.HasPrefix(body, "event: "+gtoken.SSEEventReauth+"\ndata: ") || !strings.Contains(body, gtoken.ReasonTokenExpired) || !w.Flushed {
+		t.Error("error: reauth event is not correct:", body)
+	}
+}
@@ -0,0 +1,248 @@
+package gtoken
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/v2/os/gcache"
+)
+
+// Compact removes IDs of expired or removed tokens from the user indexes "user:{userID}" of GToken and its tenant views,
+// and deletes indexes without live tokens. It returns the number of removed IDs.
+// Only members shaped like ids of NewToken, NanoIDs of TokenIDLength, are pruned, and indexes are only []string in cache
+// and file mode, and sets in redis, so keys of the application under "user:", e.g. a set "user:42:roles", are left as they are.
+// The index is otherwise only pruned when its user gets a new token, so run it by StartSweeper, or on demand.
+func (m *GToken) Compact(ctx context.Context) (removed int, err error) {
+	views := []*GToken{m}
+	for _, view := range m.tenantViews {
+		views = append(views, view)
+	}
+	for _, gt := range views {
+		count, err1 := gt.compactUserIndexes(ctx)
+		removed += count
+		if err1 != nil {
+			return removed, err1
+		}
+	}
+	// keep file content up-to-date
+	if removed > 0 && m.CacheMode == CacheModeFile {
+		m.saveToFile(ctx)
+	}
+	return removed, nil
+}
+
+// StartSweeper runs Compact every interval (DefaultSweepInterval if 0) in background until ctx is done.
+// Errors are logged, and the next run tries again.
+func (m *GToken) StartSweeper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				removed, err := m.Compact(ctx)
+				if err != nil {
+					m.log(ctx, LogLevelError, errorCompact, LogFieldError, err)
+					continue
+				}
+				if removed > 0 {
+					m.log(ctx, LogLevelDebug, "user indexes compacted", "removed", removed)
+				}
+			}
+		}
+	}()
+}
+
+// compactUserIndexes compacts the user indexes in the key namespace of GToken, other tenants are left to their views
+func (m *GToken) compactUserIndexes(ctx context.Context) (removed int, err error) {
+	ctx, done := m.traceStore(ctx, StoreOpCompact)
+	defer func() {
+		done(err)
+	}()
+	prefix := m.userKey("")
+	switch m.CacheMode {
+	case CacheModeCache, CacheModeFile:
+		keys, err1 := gcache.KeyStrings(ctx)
+		if err1 != nil {
+			m.log(ctx, LogLevelError, errorGetCache, LogFieldError, err1)
+			return 0, err1
+		}
+		for _, key := range keys {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			count, err2 := m.compactCacheIndex(ctx, key)
+			removed += count
+			if err2 != nil {
+				return removed, err2
+			}
+		}
+	case CacheModeRedis:
+		// errors of indexes are logged by compactRedisIndex
+		var indexErr error
+		err = m.scanRedisKeys(ctx, escapeRedisGlob(prefix)+"*", func(keys []string) error {
+			for _, key := range keys {
+				count, err1 := m.compactRedisIndex(ctx, key)
+				removed += count
+				if err1 != nil {
+					indexErr = err1
+					return err1
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			if indexErr == nil {
+				m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err)
+			}
+			return removed, err
+		}
+	default:
+		return 0, errors.New(errorInvalidMode)
+	}
+	return removed, nil
+}
+
+func (m *GToken) compactCacheIndex(ctx context.Context, userKey string) (int, error) {
+	// NewToken may add an id in the meantime
+	unlock := lockUserIndex(userKey)
+	defer unlock()
+	tokenIdVar, err := gcache.Get(ctx, userKey)
+	if err != nil {
+		m.log(ctx, LogLevelError, errorGetCache, LogFieldError, err)
+		return 0, err
+	}
+	// not an index, e.g. "user:profile:42" of the application
+	tokenIdSlice, ok := tokenIdVar.Val().([]string)
+	if !ok {
+		return 0, nil
+	}
+	keptTokenIdSlice := make([]string, 0, len(tokenIdSlice))
+	for _, id := range tokenIdSlice {
+		if !m.isTokenID(id) {
+			keptTokenIdSlice = append(keptTokenIdSlice, id)
+			continue
+		}
+		jwtToken, err1 := m.encryptJWT(id)
+		if err1 != nil {
+			m.log(ctx, LogLevelError, errorTokenEncrypt, LogFieldError, err1)
+			return 0, err1
+		}
+		idExists, err1 := gcache.Contains(ctx, m.tokenKey(jwtToken))
+		if err1 != nil {
+			m.log(ctx, LogLevelError, errorUseCache, LogFieldError, err1)
+			return 0, err1
+		}
+		if idExists {
+			keptTokenIdSlice = append(keptTokenIdSlice, id)
+		}
+	}
+	removed := len(tokenIdSlice) - len(keptTokenIdSlice)
+	if removed == 0 {
+		return 0, nil
+	}
+	if len(keptTokenIdSlice) == 0 {
+		if _, err = gcache.Remove(ctx, userKey); err != nil {
+			m.log(ctx, LogLevelError, errorDeleteCache, LogFieldError, err)
+			return 0, err
+		}
+		return removed, nil
+	}
+	// keep the ttl of the index, which is refreshed by its newest token
+	expireIn, err := gcache.GetExpire(ctx, userKey)
+	if err != nil {
+		m.log(ctx, LogLevelError, errorGetCache, LogFieldError, err)
+		return 0, err
+	}
+	if expireIn < 0 {
+		// expired in the meantime
+		return removed, nil
+	}
+	if err = gcache.Set(ctx, userKey, keptTokenIdSlice, expireIn); err != nil {
+		m.log(ctx, LogLevelError, errorSetCache, LogFieldError, err)
+		return 0, err
+	}
+	return removed, nil
+}
+
+func (m *GToken) compactRedisIndex(ctx context.Context, userKey string) (int, error) {
+	// not an index, SMEMBERS would fail with WRONGTYPE and stop the sweep
+	keyType, err := m.redis().Type(ctx, userKey)
+	if err != nil {
+		m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err)
+		return 0, err
+	}
+	if keyType != "set" {
+		return 0, nil
+	}
+	tokenIdVar, err := m.redis().SMembers(ctx, userKey)
+	if err != nil {
+		m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err)
+		return 0, err
+	}
+	var staleTokenIds []any
+	for _, id := range tokenIdVar.Strings() {
+		if !m.isTokenID(id) {
+			continue
+		}
+		jwtToken, err1 := m.encryptJWT(id)
+		if err1 != nil {
+			m.log(ctx, LogLevelError, errorTokenEncrypt, LogFieldError, err1)
+			return 0, err1
+		}
+		counts, err1 := m.redis().Exists(ctx, m.tokenKey(jwtToken))
+		if err1 != nil {
+			m.log(ctx, LogLevelError, errorUseRedis, LogFieldError, err1)
+			return 0, err1
+		}
+		if counts == 0 {
+			staleTokenIds = append(staleTokenIds, id)
+		}
+	}
+	if len(staleTokenIds) == 0 {
+		return 0, nil
+	}
+	// SREM only removes the stale ids, so ids added in the meantime are kept.
+	// redis deletes the set when its last member is removed
+	if _, err = m.redis().SRem(ctx, userKey, staleTokenIds[0], staleTokenIds[1:]...); err != nil {
+		m.log(ctx, LogLevelError, errorDeleteCache, LogFieldError, err)
+		return 0, err
+	}
+	return len(staleTokenIds), nil
+}
+
+// isTokenID reports whether a member of an index is shaped like an id of NewToken, a NanoID of TokenIDLength.
+// Other members, e.g. of a set of the application, are never pruned.
+func (m *GToken) isTokenID(id string) bool {
+	if len(id) != int(m.settings().config.TokenIDLength) {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// escapeRedisGlob escapes the special characters of SCAN MATCH patterns, e.g. in tenant ids and user ids
+func escapeRedisGlob(pattern string) string {
+	var builder strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			builder.WriteByte('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
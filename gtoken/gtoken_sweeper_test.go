@@ -0,0 +1,150 @@
+package gtoken_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/v2/os/gcache"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+func TestCompact(t *testing.T) {
+	t.Log("test: prune user indexes")
+	ctx := context.Background()
+	gToken := &gtoken.GToken{
+		ExpireIn:       500 * time.Millisecond,
+		Tenants:        map[string]*gtoken.Tenant{"compact": nil, "compact-acme": nil},
+		TenantResolver: gtoken.TenantFromHeader("X-Tenant-ID"),
+	}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	// keys of the test are in the namespaces of its own tenants, so other tests sharing gcache do not change what the views see
+	gt, err := gToken.ForTenant("compact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme, err := gToken.ForTenant("compact-acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// file mode may restore indexes of former runs from the shared gcache file
+	indexKeys := []any{"tenant:compact:user:compact-a", "tenant:compact:user:compact-b", "tenant:compact-acme:user:compact-c", "tenant:compact:user:compact-d"}
+	if _, err = gcache.Remove(ctx, indexKeys...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = gcache.Remove(ctx, indexKeys...)
+	})
+	// index returns the sorted token IDs of a user index
+	index := func(key string) []string {
+		value, err1 := gcache.Get(ctx, key)
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		ids := gconv.Strings(value.Val())
+		slices.Sort(ids)
+		return ids
+	}
+	newTokenInfo := func(gt *gtoken.GToken, userID string) (string, *gtoken.TokenInfo) {
+		token, tokenInfo, err1 := gt.NewToken(ctx, userID, nil)
+		if err1 != nil {
+			t.Fatal(err1)
+		}
+		return token, tokenInfo
+	}
+	newToken := func(gt *gtoken.GToken, userID string) (string, string) {
+		token, tokenInfo := newTokenInfo(gt, userID)
+		return token, tokenInfo.TokenID
+	}
+
+	t.Log("1. NewToken prunes IDs of expired tokens")
+	newToken(gt, "compact-a")
+	_, shortTokenInfo := newTokenInfo(gt, "compact-a")
+	if err = gToken.Reload(ctx, &gtoken.Config{ExpireIn: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	_, liveID := newToken(gt, "compact-a")
+	time.Sleep(time.Until(shortTokenInfo.ExpireAt.Time) + 50*time.Millisecond)
+	if ids := index("tenant:compact:user:compact-a"); len(ids) != 3 {
+		t.Fatal("error: index should keep 3 IDs before pruning, but:", ids)
+	}
+	_, newID := newToken(gt, "compact-a")
+	if ids, expected := index("tenant:compact:user:compact-a"), []string{liveID, newID}; !slices.Equal(ids, sorted(expected)) {
+		t.Error("error: index should be", expected, "but:", ids)
+	}
+
+	t.Log("2. Compact prunes all users of all tenants")
+	removedToken, _ := newToken(gt, "compact-b")
+	_, keptID := newToken(gt, "compact-b")
+	acmeToken, _ := newToken(acme, "compact-c")
+	// keys of the application under "user:" are not indexes
+	if err = gcache.Set(ctx, "tenant:compact:user:profile:compact", map[string]any{"name": "compact"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// nor are lists of the application, whose members are not token ids
+	if err = gcache.Set(ctx, "tenant:compact:user:42:roles", []string{"admin", "editor"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = gcache.Remove(ctx, "tenant:compact:user:profile:compact", "tenant:compact:user:42:roles")
+	}()
+	// tokens evicted from the store without RemoveToken, e.g. by a restart of file mode or redis eviction
+	if _, err = gcache.Remove(ctx, "tenant:compact:jwt:"+removedToken, "tenant:compact-acme:jwt:"+acmeToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = gToken.Compact(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ids := index("tenant:compact:user:compact-b"); !slices.Equal(ids, []string{keptID}) {
+		t.Error("error: index should be", keptID, "but:", ids)
+	}
+	if ok, _ := gcache.Contains(ctx, "tenant:compact-acme:user:compact-c"); ok {
+		t.Error("error: index without live tokens should be removed")
+	}
+	if ok, _ := gcache.Contains(ctx, "tenant:compact:user:profile:compact"); !ok {
+		t.Error("error: key of the application should be kept")
+	}
+	if roles := index("tenant:compact:user:42:roles"); !slices.Equal(roles, []string{"admin", "editor"}) {
+		t.Error("error: list of the application should be kept, but:", roles)
+	}
+	for _, view := range []*gtoken.GToken{gt, acme} {
+		if removed, err1 := view.Compact(ctx); err1 != nil || removed != 0 {
+			t.Error("error: nothing should be removed again, but:", removed, err1)
+		}
+	}
+	removedToken, _ = newToken(acme, "compact-c")
+	if _, err = gcache.Remove(ctx, "tenant:compact-acme:jwt:"+removedToken); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err1 := acme.Compact(ctx); err1 != nil || removed != 1 {
+		t.Error("error: 1 ID should be removed, but:", removed, err1)
+	}
+
+	t.Log("3. StartSweeper compacts until ctx is done")
+	removedToken, _ = newToken(gt, "compact-d")
+	if _, err = gcache.Remove(ctx, "tenant:compact:jwt:"+removedToken); err != nil {
+		t.Fatal(err)
+	}
+	sweepCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	gToken.StartSweeper(sweepCtx, 10*time.Millisecond)
+	for i := 0; i < 100; i++ {
+		if ok, _ := gcache.Contains(ctx, "tenant:compact:user:compact-d"); !ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ok, _ := gcache.Contains(ctx, "tenant:compact:user:compact-d"); ok {
+		t.Error("error: sweeper should remove the index")
+	}
+}
+
+func sorted(values []string) []string {
+	values = slices.Clone(values)
+	slices.Sort(values)
+	return values
+}
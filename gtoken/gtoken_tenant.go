@@ -0,0 +1,102 @@
+package gtoken
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Tenant holds the settings which differ between tenants. Empty fields fall back to the ones of GToken.
+type Tenant struct {
+	SecretKey []byte
+	ExpireIn  time.Duration
+}
+
+// TenantResolver returns the tenant id of a request, or "" if it cannot be resolved
+type TenantResolver func(r *http.Request) string
+
+// TenantFromHeader resolves the tenant from a request header, e.g. "X-Tenant-ID"
+func TenantFromHeader(name string) TenantResolver {
+	return func(r *http.Request) string {
+		return strings.TrimSpace(r.Header.Get(name))
+	}
+}
+
+// TenantFromHost resolves the tenant from the subdomain, e.g. "acme" of "acme.example.com:8080"
+func TenantFromHost() TenantResolver {
+	return func(r *http.Request) string {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		labels := strings.Split(host, ".")
+		if len(labels) < 3 {
+			return ""
+		}
+		return labels[0]
+	}
+}
+
+// TenantFromPathPrefix resolves the tenant from the first path segment, e.g. "acme" of "/acme/orders"
+func TenantFromPathPrefix() TenantResolver {
+	return func(r *http.Request) string {
+		tenantID, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		return tenantID
+	}
+}
+
+// initTenants builds a view of GToken for every tenant.
+// A view shares the cache mode and hooks of GToken, but uses the secret, expiry and key namespace of its tenant.
+func (m *GToken) initTenants() error {
+	m.tenantViews = make(map[string]*GToken, len(m.Tenants))
+	for tenantID, tenant := range m.Tenants {
+		if tenantID == "" || strings.Contains(tenantID, ":") {
+			return fmt.Errorf("%s %q", errorInvalidTenant, tenantID)
+		}
+		view := *m
+		view.Tenants = nil
+		view.TenantResolver = nil
+		view.tenantViews = nil
+		view.current = nil // built by storeSettings with the ExpireIn of the tenant
+		view.tenantID = tenantID
+		view.keyPrefix = fmt.Sprintf("%s%s:", DefaultPrefixTenant, tenantID)
+		if tenant != nil && len(tenant.SecretKey) > 0 {
+			view.SecretKey = tenant.SecretKey
+		}
+		if tenant != nil && tenant.ExpireIn > 0 {
+			view.ExpireIn = tenant.ExpireIn
+		}
+		m.tenantViews[tenantID] = &view
+	}
+	return nil
+}
+
+// ForTenant returns the view of GToken for a tenant, which issues and validates tokens of this tenant only.
+// It is available after Init.
+func (m *GToken) ForTenant(tenantID string) (*GToken, error) {
+	view, ok := m.tenantViews[tenantID]
+	if !ok {
+		return nil, fmt.Errorf("%s %q", errorTenantNotFound, tenantID)
+	}
+	return view, nil
+}
+
+// ForRequest returns the view of GToken for the tenant resolved by TenantResolver.
+// If TenantResolver is nil, GToken itself is returned, so it is also fine for a single tenant server.
+func (m *GToken) ForRequest(r *http.Request) (*GToken, error) {
+	if m.TenantResolver == nil {
+		return m, nil
+	}
+	return m.ForTenant(m.TenantResolver(r))
+}
+
+// checkTenant rejects a token issued for another tenant
+func (m *GToken) checkTenant(tokenInfo *TokenInfo) error {
+	if tokenInfo.TenantID != m.tenantID {
+		return errors.New(errorTenantMismatch)
+	}
+	return nil
+}
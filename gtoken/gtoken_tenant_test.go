@@ -0,0 +1,96 @@
+package gtoken
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTenantResolver(t *testing.T) {
+	t.Log("test: tenant resolvers")
+	r := httptest.NewRequest(http.MethodGet, "http://acme.example.com:8080/globex/orders", nil)
+	r.Header.Set("X-Tenant-ID", "initech")
+	if id := TenantFromHeader("X-Tenant-ID")(r); id != "initech" {
+		t.Error("error: tenant from header:", id)
+	}
+	if id := TenantFromHost()(r); id != "acme" {
+		t.Error("error: tenant from host:", id)
+	}
+	if id := TenantFromPathPrefix()(r); id != "globex" {
+		t.Error("error: tenant from path prefix:", id)
+	}
+	if id := TenantFromHost()(httptest.NewRequest(http.MethodGet, "http://localhost/", nil)); id != "" {
+		t.Error("error: tenant from host without subdomain:", id)
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	t.Log("test: tenant isolation")
+	ctx := context.Background()
+	tenantUserID := "tenant-user"
+	gToken := &GToken{
+		Tenants: map[string]*Tenant{
+			"a": {SecretKey: []byte("secret-a"), ExpireIn: time.Hour},
+			"b": {}, // share the secret of GToken
+		},
+		TenantResolver: TenantFromHeader("X-Tenant-ID"),
+	}
+	if !gToken.Init(ctx) {
+		t.Fatal("init failed")
+	}
+	tenantA, err := gToken.ForTenant("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantB, err := gToken.ForTenant("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = gToken.ForTenant("c"); err == nil {
+		t.Error("error: unknown tenant is not detected")
+	}
+
+	t.Log("1. a token is valid under its own tenant")
+	tokenA, tokenInfo, err := tenantA.NewToken(ctx, tenantUserID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokenInfo.TenantID != "a" || tenantA.ExpireIn != time.Hour {
+		t.Error("error: tenant settings are not applied", tokenInfo)
+	}
+	if _, err = tenantA.ValidateToken(ctx, tokenA); err != nil {
+		t.Error("error:", err)
+	}
+
+	t.Log("2. a token never validates under another tenant or without tenant")
+	if _, err = tenantB.ValidateToken(ctx, tokenA); err == nil {
+		t.Error("error: token of tenant a validates under tenant b")
+	}
+	if _, err = gToken.ValidateToken(ctx, tokenA); err == nil {
+		t.Error("error: token of tenant a validates without tenant")
+	}
+
+	t.Log("3. tenants sharing a secret are isolated as well")
+	tokenB, _, err := tenantB.NewToken(ctx, tenantUserID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = gToken.ValidateToken(ctx, tokenB); err == nil {
+		t.Error("error: token of tenant b validates without tenant")
+	}
+
+	t.Log("4. ForRequest resolves the tenant view")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Tenant-ID", "b")
+	view, err := gToken.ForRequest(r)
+	if err != nil || view != tenantB {
+		t.Error("error: tenant view is not resolved", err)
+	}
+
+	t.Log("5. Tenants without TenantResolver is rejected")
+	if (&GToken{Tenants: map[string]*Tenant{"a": {}}}).Init(ctx) {
+		t.Error("error: missing TenantResolver is not detected")
+	}
+}
@@ -2,11 +2,14 @@ package gtoken_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	_ "github.com/gogf/gf/contrib/nosql/redis/v2"
 	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/gogf/gf/v2/encoding/gjson"
 	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
 	"github.com/mayugene/gtoken/gtoken"
 )
 
@@ -37,17 +40,19 @@ func TestEncryptDecryptToken(t *testing.T) {
 
 	t.Run("test redis cache", func(t *testing.T) {
 		t.Log("use cache mode: redis")
-		// Although it is convenient to use g.Redis()
-		// But don't forget to import _ "github.com/gogf/gf/contrib/nosql/redis/v2", or it will panic
-		redisConfig := gredis.Config{
+		// inject a client, so tests never touch the default group of g.Redis()
+		// don't forget to import _ "github.com/gogf/gf/contrib/nosql/redis/v2", or gredis.New fails
+		redis, err := gredis.New(&gredis.Config{
 			Address: redisAddress,
 			Db:      1,
 			Pass:    "",
+		})
+		if err != nil {
+			t.Fatal(err)
 		}
-		gredis.SetConfig(&redisConfig)
-		gToken := &gtoken.GToken{CacheMode: gtoken.CacheModeRedis}
+		gToken := &gtoken.GToken{CacheMode: gtoken.CacheModeRedis, Redis: redis}
 		gToken.Init(ctx)
-		_, err := g.Redis().DBSize(ctx)
+		_, err = redis.DBSize(ctx)
 		if err != nil {
 			t.Error("test redis failed: cannot connect to redis server")
 		} else {
@@ -57,6 +62,25 @@ func TestEncryptDecryptToken(t *testing.T) {
 	})
 }
 
+func TestContext(t *testing.T) {
+	t.Log("test: token info in context")
+	ctx := context.Background()
+	if _, ok := gtoken.FromContext(ctx); ok {
+		t.Error("error: anonymous context should not carry token info")
+	}
+	if gtoken.UserIDFromContext(ctx) != "" || gtoken.TokenIDFromContext(ctx) != "" {
+		t.Error("error: anonymous context should return empty ids")
+	}
+	ctx = gtoken.NewContext(ctx, &gtoken.TokenInfo{UserID: userId, TokenID: "abc"})
+	tokenInfo, ok := gtoken.FromContext(ctx)
+	if !ok || tokenInfo.UserID != userId {
+		t.Error("error: token info is not found in context")
+	}
+	if gtoken.UserIDFromContext(ctx) != userId || gtoken.TokenIDFromContext(ctx) != "abc" {
+		t.Error("error: ids in context are not correct")
+	}
+}
+
 func BenchmarkEncryptDecryptToken(b *testing.B) {
 	b.Log("benchmark: encrypt and decrypt token")
 
@@ -132,3 +156,122 @@ func testExtraData(t *testing.T, ctx context.Context, gToken *gtoken.GToken) {
 		t.Error("remove token failed")
 	}
 }
+
+type routeMetaPublicReq struct {
+	g.Meta `path:"/meta/public" method:"get" auth:"false"`
+}
+
+type routeMetaProtectedReq struct {
+	g.Meta `path:"/meta/protected" method:"get" auth:"true"`
+}
+
+type routeMetaDefaultReq struct {
+	g.Meta `path:"/meta/default" method:"get"`
+}
+
+type routeMetaOptionalReq struct {
+	g.Meta `path:"/meta/optional" method:"get" auth:"optional"`
+}
+
+type routeMetaController struct{}
+
+func (c *routeMetaController) Public(ctx context.Context, req *routeMetaPublicReq) (res *gtoken.DefaultResponse, err error) {
+	return
+}
+
+func (c *routeMetaController) Protected(ctx context.Context, req *routeMetaProtectedReq) (res *gtoken.DefaultResponse, err error) {
+	return
+}
+
+func (c *routeMetaController) Default(ctx context.Context, req *routeMetaDefaultReq) (res *gtoken.DefaultResponse, err error) {
+	return
+}
+
+func (c *routeMetaController) Optional(ctx context.Context, req *routeMetaOptionalReq) (res *gtoken.DefaultResponse, err error) {
+	return &gtoken.DefaultResponse{
+		Msg:  g.RequestFromCtx(ctx).GetCtxVar("username").String(),
+		Data: gtoken.UserIDFromContext(ctx),
+	}, nil
+}
+
+func TestRouteMetaTag(t *testing.T) {
+	t.Log("test: auth declarations in g.Meta")
+	ctx := context.Background()
+	gToken := &gtoken.GToken{
+		PublicPaths: []string{"/meta/protected", "/meta/default"}, // meta tags take priority over public paths
+	}
+
+	s := g.Server("route-meta")
+	s.SetPort(8082)
+	s.Group("/", func(group *ghttp.RouterGroup) {
+		group.Middleware(ghttp.MiddlewareHandlerResponse)
+		err := gToken.UseMiddleware(ctx, group)
+		if err != nil {
+			t.Fatal(err)
+		}
+		group.Bind(&routeMetaController{})
+	})
+	err := s.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Shutdown()
+	}()
+
+	getResponse := func(path string, token string) *gtoken.DefaultResponse {
+		client := g.Client()
+		if token != "" {
+			client.SetHeader("Authorization", gtoken.PrefixBearer+token)
+		}
+		content := client.GetContent(ctx, fmt.Sprintf("http://127.0.0.1:8082%s", path))
+		res := gtoken.DefaultResponse{}
+		if err1 := gjson.DecodeTo(content, &res); err1 != nil {
+			t.Error("error:", err1)
+		}
+		return &res
+	}
+	getCode := func(path string) int {
+		return getResponse(path, "").Code
+	}
+
+	t.Log("1. auth:\"false\" is public")
+	if code := getCode("/meta/public"); code != gtoken.DefaultCodeOK {
+		t.Errorf("code should be %d, but: %d", gtoken.DefaultCodeOK, code)
+	}
+	t.Log("2. auth:\"true\" is protected even if it is in public paths")
+	if code := getCode("/meta/protected"); code != gtoken.DefaultCodeUnauthorized {
+		t.Errorf("code should be %d, but: %d", gtoken.DefaultCodeUnauthorized, code)
+	}
+	t.Log("3. no auth tag falls back to public paths")
+	if code := getCode("/meta/default"); code != gtoken.DefaultCodeOK {
+		t.Errorf("code should be %d, but: %d", gtoken.DefaultCodeOK, code)
+	}
+
+	t.Log("4. auth:\"optional\" goes on anonymously without a token")
+	if res := getResponse("/meta/optional", ""); res.Code != gtoken.DefaultCodeOK {
+		t.Errorf("code should be %d, but: %v", gtoken.DefaultCodeOK, res)
+	}
+	t.Log("5. auth:\"optional\" populates the context with a valid token")
+	token, _, err := gToken.NewToken(ctx, userId, g.Map{"username": "John Doe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := getResponse("/meta/optional", token); gjson.New(res.Data).Get("msg").String() != "John Doe" {
+		t.Error("error: context is not populated", res)
+	} else if gjson.New(res.Data).Get("data").String() != userId {
+		t.Error("error: token info is not in context", res)
+	}
+	t.Log("6. auth:\"optional\" treats an invalid token as anonymous unless RejectInvalid is true")
+	if res := getResponse("/meta/optional", "invalid"); res.Code != gtoken.DefaultCodeOK {
+		t.Errorf("code should be %d, but: %v", gtoken.DefaultCodeOK, res)
+	}
+	cfg := gToken.CurrentConfig()
+	cfg.RejectInvalid = true
+	if err := gToken.Reload(ctx, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if res := getResponse("/meta/optional", "invalid"); res.Code != gtoken.DefaultCodeUnauthorized {
+		t.Errorf("code should be %d, but: %v", gtoken.DefaultCodeUnauthorized, res)
+	}
+}
@@ -0,0 +1,86 @@
+package gtoken
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan starts a span under the trace in ctx by TracerProvider, or the global one registered by otel.SetTracerProvider
+func (m *GToken) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	provider := m.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	attrs = append(attrs,
+		attribute.String(TraceAttrCacheMode, m.backendName()),
+		attribute.String(TraceAttrTenant, m.tenantID),
+	)
+	return provider.Tracer(InstrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan sets the outcome and ends the span. The category of an error is recorded as the span status.
+func endSpan(span trace.Span, outcome string, err error) {
+	span.SetAttributes(attribute.String(TraceAttrOutcome, outcome))
+	if err != nil {
+		span.SetStatus(codes.Error, errorCategory(err))
+	}
+	span.End()
+}
+
+// errorCategory returns the fixed text of an error of gtoken, or errorUseCache for others.
+// Errors of backends are never recorded as is, since gredis errors carry command arguments like "jwt:{token}".
+func errorCategory(err error) string {
+	switch msg := err.Error(); msg {
+	case errorForbidden, errorTokenEncrypt, errorInvalidMode:
+		return msg
+	default:
+		if strings.HasPrefix(msg, errorTenantNotFound) {
+			// carries the tenant id resolved from the request
+			return errorTenantNotFound
+		}
+		return validationReason(err)
+	}
+}
+
+// endTokenSpan ends a span of NewToken or ValidateToken with the hashed token id
+func endTokenSpan(span trace.Span, tokenInfo *TokenInfo, err error) {
+	if tokenInfo != nil {
+		span.SetAttributes(attribute.String(TraceAttrTokenIDHash, hashTokenID(tokenInfo.TokenID)))
+	}
+	if err != nil {
+		endSpan(span, MetricOutcomeFailure, err)
+		return
+	}
+	endSpan(span, MetricOutcomeSuccess, nil)
+}
+
+// traceStore starts a span of a store operation, and returns a func which ends it and records the duration
+//
+//	ctx, done := m.traceStore(ctx, StoreOpGet)
+//	defer func() { done(err) }()
+func (m *GToken) traceStore(ctx context.Context, operation string) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := m.startSpan(ctx, SpanStorePrefix+operation, attribute.String(TraceAttrOperation, operation))
+	return ctx, func(err error) {
+		m.observeStore(ctx, operation, start)
+		if err != nil {
+			endSpan(span, MetricOutcomeFailure, err)
+			return
+		}
+		endSpan(span, MetricOutcomeSuccess, nil)
+	}
+}
+
+// hashTokenID keeps token ids out of traces while spans of the same token can still be correlated
+func hashTokenID(tokenID string) string {
+	sum := sha256.Sum256([]byte(tokenID))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,121 @@
+package gtoken_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/mayugene/gtoken/gtoken"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	t.Log("test: otel spans of auth and store calls")
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	gToken := &gtoken.GToken{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))}
+
+	s := g.Server("tracing")
+	s.SetPort(8087)
+	s.Group("/", func(group *ghttp.RouterGroup) {
+		err := gToken.UseMiddleware(ctx, group)
+		if err != nil {
+			t.Fatal(err)
+		}
+		group.ALL("/user", func(r *ghttp.Request) {
+			r.Response.Write("ok")
+		})
+	})
+	err := s.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Shutdown()
+	}()
+
+	// spans returns ended spans by name, and forgets them
+	spans := func() map[string][]sdktrace.ReadOnlySpan {
+		result := map[string][]sdktrace.ReadOnlySpan{}
+		for _, span := range recorder.Ended() {
+			result[span.Name()] = append(result[span.Name()], span)
+		}
+		recorder.Reset()
+		return result
+	}
+	attr := func(span sdktrace.ReadOnlySpan, key string) string {
+		for _, kv := range span.Attributes() {
+			if kv.Key == attribute.Key(key) {
+				return kv.Value.AsString()
+			}
+		}
+		return ""
+	}
+
+	t.Log("1. NewToken has a store span and a hashed token id")
+	token, tokenInfo, err := gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ended := spans()
+	if len(ended[gtoken.SpanNewToken]) != 1 || len(ended[gtoken.SpanStorePrefix+gtoken.StoreOpSet]) != 1 {
+		t.Fatal("error: spans are not correct:", ended)
+	}
+	newTokenSpan := ended[gtoken.SpanNewToken][0]
+	hash := attr(newTokenSpan, gtoken.TraceAttrTokenIDHash)
+	if hash == "" || hash == tokenInfo.TokenID || attr(newTokenSpan, gtoken.TraceAttrCacheMode) != "cache" {
+		t.Error("error: attributes are not correct:", newTokenSpan.Attributes())
+	}
+	storeSpan := ended[gtoken.SpanStorePrefix+gtoken.StoreOpSet][0]
+	if storeSpan.Parent().SpanID() != newTokenSpan.SpanContext().SpanID() {
+		t.Error("error: store span should be a child of NewToken")
+	}
+
+	t.Log("2. authMiddleware wraps ValidateToken and the raw token is never recorded")
+	client := g.Client()
+	client.SetPrefix("http://127.0.0.1:8087")
+	client.SetHeader("Authorization", gtoken.PrefixBearer+token)
+	if content := client.GetContent(ctx, "/user"); content != "ok" {
+		t.Error("error: request is not authorized:", content)
+	}
+	ended = spans()
+	if len(ended[gtoken.SpanAuthMiddleware]) != 1 || len(ended[gtoken.SpanValidateToken]) != 1 {
+		t.Fatal("error: spans are not correct:", ended)
+	}
+	authSpan := ended[gtoken.SpanAuthMiddleware][0]
+	validateSpan := ended[gtoken.SpanValidateToken][0]
+	if attr(authSpan, gtoken.TraceAttrOutcome) != gtoken.TraceOutcomeAuthorized || attr(authSpan, gtoken.TraceAttrRoute) != "/user" {
+		t.Error("error: authMiddleware attributes are not correct:", authSpan.Attributes())
+	}
+	if validateSpan.Parent().SpanID() != authSpan.SpanContext().SpanID() || attr(validateSpan, gtoken.TraceAttrTokenIDHash) != hash {
+		t.Error("error: ValidateToken span is not correct:", validateSpan.Attributes())
+	}
+	for _, list := range ended {
+		for _, span := range list {
+			if strings.Contains(span.Status().Description, token) {
+				t.Error("error: raw token is recorded in the status of", span.Name())
+			}
+			for _, kv := range span.Attributes() {
+				if strings.Contains(kv.Value.Emit(), token) {
+					t.Error("error: raw token is recorded in", span.Name())
+				}
+			}
+		}
+	}
+
+	t.Log("3. an invalid token is rejected")
+	client.SetHeader("Authorization", gtoken.PrefixBearer+"invalid")
+	client.GetContent(ctx, "/user")
+	ended = spans()
+	if len(ended[gtoken.SpanAuthMiddleware]) != 1 || attr(ended[gtoken.SpanAuthMiddleware][0], gtoken.TraceAttrOutcome) != gtoken.TraceOutcomeRejected {
+		t.Error("error: authMiddleware should be rejected:", ended)
+	}
+	// the status is a fixed category, errors of backends may carry keys with the raw token
+	if status := ended[gtoken.SpanValidateToken][0].Status().Description; status != "token not found" {
+		t.Error("error: status should be the category of the error, but:", status)
+	}
+}
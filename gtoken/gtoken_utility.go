@@ -1,59 +1,61 @@
 package gtoken
 
 import (
+	"context"
 	"errors"
+	"net/http"
 	"strings"
 
 	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/os/gcache"
 	"github.com/gogf/gf/v2/os/gtime"
-	"github.com/gogf/gf/v2/text/gstr"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/matoous/go-nanoid/v2"
 )
 
 func CheckAuthRequired(publicPaths []string, urlPath string, urlMethod string) bool {
 	/*
-			Checks whether a path needs to do auth
-
-			publicPaths support common formats like "/login" and restful formats like "POST:/login"
-
-			Supported URL path formats:
-			1. Prefix match
-				/test/* matches:
-					 /test/, /test/abc, /test/123, /test/123/abc
-				/test/* not matches:
-					/test, /testing
-		 	2. Full match
-				/test  matches /test
-				/test/ matches /test/
+		Checks whether a path needs to do auth
+
+		publicPaths support common formats like "/login", restful formats like "POST:/login"
+		and method lists like "GET,HEAD:/docs/*". Refer to pathMatcher for all supported patterns.
+
+		Invalid patterns are skipped here. Use GToken.Init to report them.
+		Matchers are cached by their paths, so a fixed list is only compiled once.
 	*/
-	urlMethod = strings.ToUpper(urlMethod) // ensure to be POST, PUT, etc.
-
-	for _, allowItem := range publicPaths {
-		var allowMethod, allowPath string
-		allowItemSlice := gstr.Split(allowItem, ":")
-		if len(allowItemSlice) == 2 {
-			allowMethod = strings.ToUpper(allowItemSlice[0]) // force to be POST, PUT, etc.
-			allowPath = allowItemSlice[1]
-		} else {
-			allowPath = allowItemSlice[0]
+	return !cachedPathMatcher(publicPaths).matches(urlPath, urlMethod)
+}
+
+// checkAuthMatchers caches matchers of CheckAuthRequired, the least recently used are dropped beyond its capacity
+var checkAuthMatchers = gcache.New(64)
+
+// cachedPathMatcher returns the matcher of valid patterns, compiled at the first call of the same list
+func cachedPathMatcher(patterns []string) *pathMatcher {
+	// "\n" never appears in a pattern of one line
+	key := strings.Join(patterns, "\n")
+	value, err := checkAuthMatchers.GetOrSetFuncLock(context.Background(), key, func(ctx context.Context) (any, error) {
+		matcher, err := newPathMatcher(patterns)
+		if err != nil {
+			matcher, _ = newPathMatcher(validPatterns(patterns))
 		}
+		return matcher, nil
+	}, 0)
+	if err != nil {
+		matcher, _ := newPathMatcher(validPatterns(patterns))
+		return matcher
+	}
+	return value.Val().(*pathMatcher)
+}
 
-		isMethodMatched := allowMethod == "" || allowMethod == urlMethod
-
-		if strings.HasSuffix(allowPath, "/*") {
-			// check prefix match
-			if strings.HasPrefix(urlPath, allowPath[:len(allowPath)-1]) && isMethodMatched {
-				return false
-			}
-		} else {
-			// check full match
-			if urlPath == allowPath && isMethodMatched {
-				return false
-			}
+// validPatterns filters out the patterns that cannot be compiled
+func validPatterns(patterns []string) []string {
+	valid := make([]string, 0, len(patterns))
+	for _, item := range patterns {
+		if _, err := newPathMatcher([]string{item}); err == nil {
+			valid = append(valid, item)
 		}
 	}
-	return true
+	return valid
 }
 
 func getNanoID(length uint8) string {
@@ -68,11 +70,26 @@ func getNanoID(length uint8) string {
 	return id
 }
 
-// encryptJWT returns a valid jwt token
-func encryptJWT(secretKey []byte, id string) (token string, err error) {
+// tokenClaims only adds the optional "scope" claim (RFC 8693) to the registered claims
+type tokenClaims struct {
+	Scope string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// encryptJWT returns a valid HS256 jwt token. Scopes are put in the "scope" claim separated by spaces.
+func encryptJWT(secretKey []byte, id string, scopes ...string) (token string, err error) {
+	return signJWT(jwt.SigningMethodHS256, secretKey, id, scopes...)
+}
+
+// decryptJWT returns the tokenID in claims of a HS256 jwt token
+func decryptJWT(secretKey []byte, token string) (tokenID string, err error) {
+	return parseJWT(jwt.SigningMethodHS256, secretKey, token)
+}
+
+func signJWT(method jwt.SigningMethod, secretKey []byte, id string, scopes ...string) (token string, err error) {
 	jwtToken := jwt.NewWithClaims(
-		jwt.SigningMethodHS256,
-		jwt.RegisteredClaims{ID: id},
+		method,
+		tokenClaims{Scope: strings.Join(scopes, " "), RegisteredClaims: jwt.RegisteredClaims{ID: id}},
 	)
 	token, err = jwtToken.SignedString(secretKey)
 	if err != nil {
@@ -81,33 +98,80 @@ func encryptJWT(secretKey []byte, id string) (token string, err error) {
 	return token, nil
 }
 
-// decryptJWT returns the tokenID in jwt claims
-func decryptJWT(secretKey []byte, token string) (tokenID string, err error) {
+func parseJWT(method jwt.SigningMethod, secretKey []byte, token string) (tokenID string, err error) {
 	if token == "" {
 		return "", errors.New(errorTokenEmpty)
 	}
-	parse, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+	parse, err := jwt.ParseWithClaims(token, &tokenClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return secretKey, nil
-	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	}, jwt.WithValidMethods([]string{method.Alg()}))
 	if err != nil {
 		return
 	}
 	if !parse.Valid {
 		return "", errors.New(errorTokenDecode)
 	}
-	return parse.Claims.(*jwt.RegisteredClaims).ID, nil
+	return parse.Claims.(*tokenClaims).ID, nil
+}
+
+// signingMethod returns the HMAC method of Algorithm, which is checked in Init
+func (m *GToken) signingMethod() jwt.SigningMethod {
+	if method, ok := jwt.GetSigningMethod(m.Algorithm).(*jwt.SigningMethodHMAC); ok {
+		return method
+	}
+	return jwt.SigningMethodHS256
+}
+
+// encryptJWT is encryptJWT by SecretKey and Algorithm
+func (m *GToken) encryptJWT(id string, scopes ...string) (token string, err error) {
+	return signJWT(m.signingMethod(), m.SecretKey, id, scopes...)
+}
+
+// decryptJWT is decryptJWT by SecretKey and Algorithm
+func (m *GToken) decryptJWT(token string) (tokenID string, err error) {
+	return parseJWT(m.signingMethod(), m.SecretKey, token)
 }
 
 // ParseRequestToken tries to get token from the following path by priority:
 // 1. header.Authorization
-// 2. token
+// 2. header.Sec-WebSocket-Protocol, like "bearer, {token}", only of a WebSocket upgrade
+// 3. token
 func ParseRequestToken(r *ghttp.Request) string {
 	// 1. from header.Authorization
-	authHeader := r.Header.Get("Authorization")
-	if len(authHeader) > 7 && strings.HasPrefix(authHeader, PrefixBearer) {
-		return authHeader[7:]
+	if token := parseAuthorizationHeader(r.Header.Get("Authorization")); token != "" {
+		return token
 	}
-	// 2. from token
+	// 2. from header.Sec-WebSocket-Protocol
+	if token := parseUpgradeToken(r.Header); token != "" {
+		return token
+	}
+	// 3. from token
 	tokenInRequest := r.Get(TokenKeyInRequest).String()
 	return strings.TrimPrefix(tokenInRequest, PrefixBearer)
 }
+
+// ParseHTTPRequestToken is ParseRequestToken for a plain *http.Request, by priority:
+// 1. header.Authorization
+// 2. header.Sec-WebSocket-Protocol, like "bearer, {token}", only of a WebSocket upgrade
+// 3. token in query
+//
+// The body is never read, so it is left intact for next, e.g. a reverse proxy.
+func ParseHTTPRequestToken(r *http.Request) string {
+	// 1. from header.Authorization
+	if token := parseAuthorizationHeader(r.Header.Get("Authorization")); token != "" {
+		return token
+	}
+	// 2. from header.Sec-WebSocket-Protocol
+	if token := parseUpgradeToken(r.Header); token != "" {
+		return token
+	}
+	// 3. from token, FormValue would drain the body of a POST
+	return strings.TrimPrefix(r.URL.Query().Get(TokenKeyInRequest), PrefixBearer)
+}
+
+func parseAuthorizationHeader(authHeader string) string {
+	if len(authHeader) > len(PrefixBearer) && strings.HasPrefix(authHeader, PrefixBearer) {
+		return authHeader[len(PrefixBearer):]
+	}
+	return ""
+}
@@ -58,6 +58,17 @@ func TestCheckAuthRequired(t *testing.T) {
 	if !CheckAuthRequired(publicPaths, "/test", "delete") {
 		t.Error("error:", "/test protected prefix match is not detected")
 	}
+
+	// test the matcher is compiled once per list, and invalid patterns are skipped
+	if cachedPathMatcher(publicPaths) != cachedPathMatcher(publicPaths) {
+		t.Error("error:", "matcher of the same paths is compiled again")
+	}
+	if CheckAuthRequired([]string{"~(", "/login"}, "/login", "get") {
+		t.Error("error:", "valid paths beside an invalid one are not matched")
+	}
+	if !CheckAuthRequired(nil, "/login", "get") {
+		t.Error("error:", "no public paths should protect every path")
+	}
 }
 
 func TestGetNanoID(t *testing.T) {
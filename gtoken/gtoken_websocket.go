@@ -0,0 +1,207 @@
+package gtoken
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gorilla/websocket"
+)
+
+// Browsers cannot set headers for WebSocket, so a token can be sent as subprotocols instead:
+//
+//	new WebSocket(url, ["bearer", token])
+//
+// which results in "Sec-WebSocket-Protocol: bearer, {token}". UpgradeWebSocket answers with "bearer" as browsers require.
+
+// parseWebSocketProtocolToken returns the item after "bearer" in Sec-WebSocket-Protocol
+func parseWebSocketProtocolToken(header string) string {
+	protocols := strings.Split(header, ",")
+	for i := 0; i < len(protocols)-1; i++ {
+		if strings.EqualFold(strings.TrimSpace(protocols[i]), WebSocketProtocolBearer) {
+			return strings.TrimSpace(protocols[i+1])
+		}
+	}
+	return ""
+}
+
+// parseUpgradeToken returns the token in Sec-WebSocket-Protocol of a WebSocket upgrade.
+// The header means nothing to other requests, so it is not read from them.
+func parseUpgradeToken(header http.Header) string {
+	if !strings.EqualFold(header.Get("Upgrade"), "websocket") {
+		return ""
+	}
+	return parseWebSocketProtocolToken(header.Get("Sec-WebSocket-Protocol"))
+}
+
+// webSocketRegistry keeps WebSocket connections by token, so they can be closed when the token is revoked.
+// It is shared by tenant views.
+type webSocketRegistry struct {
+	mu    sync.Mutex
+	conns map[string]map[*websocket.Conn]struct{} // cache key of token id -> connections
+	keys  map[*websocket.Conn]string              // connection -> cache key of token id
+}
+
+func newWebSocketRegistry() *webSocketRegistry {
+	return &webSocketRegistry{
+		conns: map[string]map[*websocket.Conn]struct{}{},
+		keys:  map[*websocket.Conn]string{},
+	}
+}
+
+func (ws *webSocketRegistry) add(key string, conn *websocket.Conn) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.conns[key] == nil {
+		ws.conns[key] = map[*websocket.Conn]struct{}{}
+	}
+	ws.conns[key][conn] = struct{}{}
+	ws.keys[conn] = key
+}
+
+func (ws *webSocketRegistry) remove(conn *websocket.Conn) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	key, ok := ws.keys[conn]
+	if !ok {
+		return
+	}
+	delete(ws.keys, conn)
+	delete(ws.conns[key], conn)
+	if len(ws.conns[key]) == 0 {
+		delete(ws.conns, key)
+	}
+}
+
+// close closes and forgets all connections of a token with a close message
+func (ws *webSocketRegistry) close(key string, reason string) {
+	ws.mu.Lock()
+	conns := ws.conns[key]
+	delete(ws.conns, key)
+	for conn := range conns {
+		delete(ws.keys, conn)
+	}
+	ws.mu.Unlock()
+	for conn := range conns {
+		closeWebSocket(conn, WebSocketCloseUnauthorized, reason)
+	}
+}
+
+// TrackWebSocket binds a connection to the token, so RemoveToken, RemoveUserTokens and SingleSession close it.
+// UpgradeWebSocket and AuthenticateWebSocket call it already.
+func (m *GToken) TrackWebSocket(tokenInfo *TokenInfo, conn *websocket.Conn) {
+	if m.webSockets != nil {
+		m.webSockets.add(m.keyPrefix+tokenInfo.TokenID, conn)
+	}
+}
+
+// UntrackWebSocket forgets a connection. Call it when a handler is done with the connection,
+// since a connection dropped without close message cannot be noticed.
+func (m *GToken) UntrackWebSocket(conn *websocket.Conn) {
+	if m.webSockets != nil {
+		m.webSockets.remove(conn)
+	}
+}
+
+// closeWebSockets closes connections of the given token IDs
+func (m *GToken) closeWebSockets(reason string, tokenIDs ...string) {
+	if m.webSockets == nil {
+		return
+	}
+	for _, id := range tokenIDs {
+		m.webSockets.close(m.keyPrefix+id, reason)
+	}
+}
+
+// UpgradeWebSocket upgrades a request authenticated by authMiddleware, and tracks the connection by its token.
+// If the token is sent by subprotocols, "bearer" is selected as the subprotocol.
+// The connection is not tracked on public paths or for anonymous requests on optional paths.
+//
+//	s.BindHandler("/ws", func(r *ghttp.Request) {
+//	    conn, err := gToken.UpgradeWebSocket(r)
+//	    if err != nil {
+//	        return
+//	    }
+//	    defer gToken.UntrackWebSocket(conn)
+//	    ...
+//	})
+func (m *GToken) UpgradeWebSocket(r *ghttp.Request) (*websocket.Conn, error) {
+	// the default CheckOrigin of gorilla only allows same origin requests
+	upgrader := websocket.Upgrader{}
+	if parseWebSocketProtocolToken(r.Header.Get("Sec-WebSocket-Protocol")) != "" {
+		upgrader.Subprotocols = []string{WebSocketProtocolBearer}
+	}
+	conn, err := upgrader.Upgrade(r.Response.Writer, r.Request, nil)
+	if err != nil {
+		return nil, err
+	}
+	if tokenInfo, ok := FromContext(r.Context()); ok {
+		m.trackUntilClosed(tokenInfo, conn)
+	}
+	return conn, nil
+}
+
+// AuthenticateWebSocket performs a first-message handshake on a connection upgraded without token,
+// e.g. on a public path. The first message is either the token itself or {"token": "..."}.
+// The token must satisfy ScopeRules, `scopes` in g.Meta and Policy of the request, like authMiddleware.
+// The connection is closed with 4401 if the handshake fails, or 4403 if it is forbidden, or tracked by its token if it succeeds.
+func (m *GToken) AuthenticateWebSocket(r *ghttp.Request, conn *websocket.Conn) (*TokenInfo, error) {
+	tokenInfo, err := m.webSocketHandshake(r.Context(), conn)
+	if err != nil {
+		closeWebSocket(conn, WebSocketCloseUnauthorized, errorUnauthorized)
+		return nil, err
+	}
+	if required := m.requiredScopes(r); !tokenInfo.HasScopes(required...) {
+		closeWebSocket(conn, WebSocketCloseForbidden, ReasonInsufficientScope)
+		return nil, errors.New(errorForbidden)
+	}
+	if allow, reason := m.evaluatePolicy(r, tokenInfo); !allow {
+		closeWebSocket(conn, WebSocketCloseForbidden, reason)
+		return nil, errors.New(errorForbidden)
+	}
+	m.trackUntilClosed(tokenInfo, conn)
+	return tokenInfo, nil
+}
+
+// closeWebSocket closes a connection with a close message
+func closeWebSocket(conn *websocket.Conn, code int, reason string) {
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	_ = conn.Close()
+}
+
+// trackUntilClosed tracks a connection until the client closes it
+func (m *GToken) trackUntilClosed(tokenInfo *TokenInfo, conn *websocket.Conn) {
+	m.TrackWebSocket(tokenInfo, conn)
+	conn.SetCloseHandler(func(code int, text string) error {
+		m.UntrackWebSocket(conn)
+		// the same as the default close handler of gorilla
+		return conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(time.Second))
+	})
+}
+
+func (m *GToken) webSocketHandshake(ctx context.Context, conn *websocket.Conn) (*TokenInfo, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(DefaultWebSocketHandshakeTimeout)); err != nil {
+		return nil, err
+	}
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	token := strings.TrimSpace(string(message))
+	if strings.HasPrefix(token, "{") {
+		token = gjson.New(message).Get(TokenKeyInRequest).String()
+	}
+	token = strings.TrimPrefix(token, PrefixBearer)
+	if token == "" {
+		return nil, errors.New(errorTokenEmpty)
+	}
+	return m.ValidateToken(ctx, token)
+}
@@ -0,0 +1,164 @@
+package gtoken_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gorilla/websocket"
+	"github.com/mayugene/gtoken/gtoken"
+)
+
+func TestWebSocket(t *testing.T) {
+	t.Log("test: WebSocket authentication and revocation")
+	ctx := context.Background()
+	gToken := &gtoken.GToken{
+		PublicPaths: []string{"/ws/handshake", "/ws/admin"},
+		ScopeRules:  map[string][]string{"/ws/admin": {"admin"}},
+	}
+
+	echo := func(conn *websocket.Conn) {
+		defer gToken.UntrackWebSocket(conn)
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = conn.WriteMessage(msgType, msg); err != nil {
+				return
+			}
+		}
+	}
+	s := g.Server("websocket")
+	s.SetPort(8086)
+	s.Group("/", func(group *ghttp.RouterGroup) {
+		err := gToken.UseMiddleware(ctx, group)
+		if err != nil {
+			t.Fatal(err)
+		}
+		group.GET("/ws", func(r *ghttp.Request) {
+			conn, err := gToken.UpgradeWebSocket(r)
+			if err != nil {
+				return
+			}
+			echo(conn)
+		})
+		handshake := func(r *ghttp.Request) {
+			conn, err := gToken.UpgradeWebSocket(r)
+			if err != nil {
+				return
+			}
+			if _, err = gToken.AuthenticateWebSocket(r, conn); err != nil {
+				return
+			}
+			echo(conn)
+		}
+		group.GET("/ws/handshake", handshake)
+		group.GET("/ws/admin", handshake)
+	})
+	err := s.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Shutdown()
+	}()
+
+	// expectClosed reads until the connection is closed by server with code
+	expectClosed := func(conn *websocket.Conn, code int) {
+		_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		_, _, err1 := conn.ReadMessage()
+		var closeErr *websocket.CloseError
+		if !errors.As(err1, &closeErr) || closeErr.Code != code {
+			t.Error("error: connection is not closed with", code, "but:", err1)
+		}
+	}
+	expectRevoked := func(conn *websocket.Conn) {
+		expectClosed(conn, gtoken.WebSocketCloseUnauthorized)
+	}
+
+	t.Log("1. token in Sec-WebSocket-Protocol")
+	token, _, err := gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer := websocket.Dialer{Subprotocols: []string{gtoken.WebSocketProtocolBearer, token}}
+	conn, resp, err := dialer.Dial("ws://127.0.0.1:8086/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if resp.Header.Get("Sec-WebSocket-Protocol") != gtoken.WebSocketProtocolBearer {
+		t.Error("error: bearer subprotocol is not selected")
+	}
+	if err = conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	if _, msg, err1 := conn.ReadMessage(); err1 != nil || string(msg) != "ping" {
+		t.Error("error: echo failed:", err1)
+	}
+
+	t.Log("2. RemoveToken closes the connection")
+	if _, err = gToken.RemoveToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	expectRevoked(conn)
+
+	t.Log("3. upgrade without token is rejected")
+	if _, _, err = websocket.DefaultDialer.Dial("ws://127.0.0.1:8086/ws", nil); err == nil {
+		t.Error("error: upgrade without token should fail")
+	}
+
+	t.Log("4. first-message handshake and RemoveUserTokens")
+	token, _, err = gToken.NewToken(ctx, userId, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handshakeConn, _, err := websocket.DefaultDialer.Dial("ws://127.0.0.1:8086/ws/handshake", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handshakeConn.Close()
+	if err = handshakeConn.WriteJSON(map[string]string{"token": token}); err != nil {
+		t.Fatal(err)
+	}
+	if err = handshakeConn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	if _, msg, err1 := handshakeConn.ReadMessage(); err1 != nil || string(msg) != "ping" {
+		t.Error("error: echo after handshake failed:", err1)
+	}
+	if _, err = gToken.RemoveUserTokens(ctx, userId); err != nil {
+		t.Fatal(err)
+	}
+	expectRevoked(handshakeConn)
+
+	t.Log("5. handshake with an invalid token is closed")
+	badConn, _, err := websocket.DefaultDialer.Dial("ws://127.0.0.1:8086/ws/handshake", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badConn.Close()
+	if err = badConn.WriteMessage(websocket.TextMessage, []byte("invalid")); err != nil {
+		t.Fatal(err)
+	}
+	expectRevoked(badConn)
+
+	t.Log("6. handshake lacking scopes of the path is closed")
+	token, _, err = gToken.NewToken(ctx, userId, nil, gtoken.WithScopes("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scopeConn, _, err := websocket.DefaultDialer.Dial("ws://127.0.0.1:8086/ws/admin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer scopeConn.Close()
+	if err = scopeConn.WriteMessage(websocket.TextMessage, []byte(token)); err != nil {
+		t.Fatal(err)
+	}
+	expectClosed(scopeConn, gtoken.WebSocketCloseForbidden)
+}